@@ -0,0 +1,66 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package agent implements a process which holds unlocked private keys in
+// memory and decrypts values on behalf of other smithy commands over a
+// Unix socket, much like ssh-agent.
+//
+// Each connection carries newline delimited JSON requests and responses:
+//
+//	{"op":"decrypt","envelope":"ENC[...]","label":"..."}
+//	{"plaintext":"<base64>"}  or  {"error":"...","code":"wrong_key"}
+package agent
+
+import (
+	"errors"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+// SocketEnv is the environment variable naming the agent socket
+const SocketEnv = "SMITHY_AUTH_SOCK"
+
+// ErrNoKeys is returned once the agent has evicted its keys
+var ErrNoKeys = errors.New("agent holds no keys")
+
+type request struct {
+	Op       string `json:"op"`
+	Envelope string `json:"envelope"`
+	Label    string `json:"label"`
+}
+
+type response struct {
+	Plaintext []byte `json:"plaintext,omitempty"`
+	Error     string `json:"error,omitempty"`
+	Code      string `json:"code,omitempty"`
+}
+
+// error codes carry the crypt sentinel errors across the socket
+var errorCodes = map[string]error{
+	"not_encrypted": crypt.ErrNotEncrypted,
+	"malformed":     crypt.ErrMalformedEnvelope,
+	"key_not_found": crypt.ErrKeyNotFound,
+	"wrong_key":     crypt.ErrWrongKey,
+	"no_keys":       ErrNoKeys,
+}
+
+// codeFor returns the code of the sentinel wrapped by err
+func codeFor(err error) string {
+	for code, sentinel := range errorCodes {
+		if errors.Is(err, sentinel) {
+			return code
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+// Client is a Decrypter which asks an agent to decrypt values
+type Client struct {
+	Socket string
+}
+
+// NewClient returns a client for the agent listening on socket
+func NewClient(socket string) *Client {
+	return &Client{Socket: socket}
+}
+
+// Decrypt sends env to the agent and returns the plaintext
+func (c *Client) Decrypt(env *crypt.Envelope, label string) ([]byte, error) {
+	conn, err := net.Dial("unix", c.Socket)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cannot reach agent: %v", crypt.ErrKeyNotFound, err)
+	}
+	defer conn.Close()
+
+	err = json.NewEncoder(conn).Encode(&request{Op: "decrypt", Envelope: env.String(), Label: label})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp response
+	err = json.NewDecoder(bufio.NewReader(conn)).Decode(&resp)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %v", err)
+	}
+	if resp.Error == "" {
+		return resp.Plaintext, nil
+	}
+
+	sentinel := errorCodes[resp.Code]
+	if sentinel == ErrNoKeys {
+		sentinel = crypt.ErrKeyNotFound
+	}
+	return nil, &remoteError{msg: resp.Error, err: sentinel}
+}
+
+// remoteError is an error reported by the agent. It unwraps to the crypt
+// sentinel matching the code sent with it.
+type remoteError struct {
+	msg string
+	err error
+}
+
+func (e *remoteError) Error() string {
+	return "agent: " + e.msg
+}
+
+func (e *remoteError) Unwrap() error {
+	return e.err
+}
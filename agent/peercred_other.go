@@ -0,0 +1,33 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package agent
+
+import (
+	"errors"
+	"net"
+)
+
+// peerCredentials reports whether checkPeer can tell who a client is.
+// Without it any local user able to reach the socket could decrypt, so
+// the agent does not start.
+const peerCredentials = false
+
+// checkPeer rejects every client as the user running it is unknown
+func checkPeer(c *net.UnixConn) error {
+	return errors.New("peer credentials are not supported on this platform")
+}
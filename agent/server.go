@@ -0,0 +1,210 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/crypt"
+)
+
+// Limits on client connections, so a client that stops reading or
+// writing cannot hold a connection open forever
+const (
+	// requestTimeout is how long a connection may wait between requests
+	requestTimeout = time.Minute
+	// responseTimeout is how long writing a response may take
+	responseTimeout = 10 * time.Second
+)
+
+// minIdleCheck is the shortest interval between checks for idleness
+const minIdleCheck = 10 * time.Millisecond
+
+// Server answers decrypt requests with the keys it holds
+type Server struct {
+	// IdleTimeout evicts the keys when no request arrives for this long.
+	// Zero keeps the keys until the server is closed.
+	IdleTimeout time.Duration
+
+	mu        sync.Mutex
+	decrypter crypt.Decrypter
+	lastUsed  time.Time
+	listener  net.Listener
+	evicted   chan struct{}
+}
+
+// NewServer returns a server decrypting with d
+func NewServer(d crypt.Decrypter) *Server {
+	return &Server{decrypter: d, evicted: make(chan struct{})}
+}
+
+// Listen creates the Unix socket at path, readable only by the current user.
+// A stale socket left behind by an earlier agent is replaced.
+func (s *Server) Listen(path string) error {
+	if !peerCredentials {
+		return fmt.Errorf("the agent is not supported on %s, which cannot tell which user a client runs as", runtime.GOOS)
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return fmt.Errorf("an agent is already listening on %s", path)
+		}
+		os.Remove(path)
+	}
+
+	l, err := listenUnix(path)
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		l.Close()
+		return err
+	}
+
+	s.listener = l
+	return nil
+}
+
+// Serve accepts connections until the server is closed or its keys are
+// evicted after being idle
+func (s *Server) Serve() error {
+	s.touch()
+	if s.IdleTimeout > 0 {
+		go s.watchIdle()
+	}
+
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.evicted:
+				return nil
+			default:
+				return err
+			}
+		}
+		go s.handle(c.(*net.UnixConn))
+	}
+}
+
+// Close stops the server and forgets its keys
+func (s *Server) Close() error {
+	s.evict()
+	return s.listener.Close()
+}
+
+// watchIdle evicts the keys and stops the server once it has been idle
+// for IdleTimeout
+func (s *Server) watchIdle() {
+	interval := s.IdleTimeout / 10
+	if interval < minIdleCheck {
+		interval = minIdleCheck
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		idle := time.Since(s.lastUsed)
+		s.mu.Unlock()
+
+		if idle >= s.IdleTimeout {
+			log.WithField("idle", idle).Info("evicting keys")
+			s.Close()
+			return
+		}
+	}
+}
+
+func (s *Server) evict() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.decrypter != nil {
+		s.decrypter = nil
+		close(s.evicted)
+	}
+}
+
+func (s *Server) touch() {
+	s.mu.Lock()
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *Server) handle(c *net.UnixConn) {
+	defer c.Close()
+
+	err := checkPeer(c)
+	if err != nil {
+		log.WithError(err).Warn("rejecting agent client")
+		return
+	}
+
+	scanner := bufio.NewScanner(c)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(c)
+	for {
+		c.SetReadDeadline(time.Now().Add(requestTimeout))
+		if !scanner.Scan() {
+			return
+		}
+
+		var req request
+		var resp response
+
+		err := json.Unmarshal(scanner.Bytes(), &req)
+		if err == nil {
+			resp.Plaintext, err = s.decrypt(&req)
+		}
+		if err != nil {
+			resp.Error, resp.Code = err.Error(), codeFor(err)
+		}
+
+		c.SetWriteDeadline(time.Now().Add(responseTimeout))
+		err = encoder.Encode(&resp)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) decrypt(req *request) ([]byte, error) {
+	if req.Op != "decrypt" {
+		return nil, fmt.Errorf("unsupported operation %q", req.Op)
+	}
+
+	s.mu.Lock()
+	d := s.decrypter
+	s.lastUsed = time.Now()
+	s.mu.Unlock()
+
+	if d == nil {
+		return nil, ErrNoKeys
+	}
+	return crypt.DecryptWith(d, req.Envelope, req.Label)
+}
@@ -0,0 +1,99 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/agent"
+	"github.com/mshindle/smithy/config"
+	"github.com/mshindle/smithy/crypt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "hold private keys in memory and decrypt for other smithy commands",
+	Long: `
+Loads and unlocks the private key once, then answers decrypt requests
+from other smithy commands over a Unix socket, similar to ssh-agent.
+Only processes running as the same user may connect. The agent runs on
+Linux, macOS and FreeBSD, where it can check the user of each client.
+
+The agent prints the variable to export before serving:
+
+    eval "$(smithy agent --timeout 1h &)"
+
+smithy decrypt uses the agent whenever SMITHY_AUTH_SOCK is set. After
+--timeout without requests the keys are evicted and the agent exits.`,
+	RunE:         runAgent,
+	SilenceUsage: true,
+}
+
+func init() {
+	RootCmd.AddCommand(agentCmd)
+	agentCmd.Flags().StringP("socket", "a", "", "socket path (default is agent.sock in the base dir)")
+	agentCmd.Flags().StringP("key", "k", "", "private key source: a file, env:NAME, stdin, fd:N or cmd:COMMAND")
+	agentCmd.Flags().Duration("timeout", 0, "evict keys after being idle this long (0 never evicts)")
+	viper.BindPFlag("agent.socket", agentCmd.Flags().Lookup("socket"))
+	viper.BindPFlag("agent.key", agentCmd.Flags().Lookup("key"))
+	viper.BindPFlag("agent.timeout", agentCmd.Flags().Lookup("timeout"))
+}
+
+func runAgent(cmd *cobra.Command, args []string) error {
+	provider, err := privateKeyProvider("agent.key")
+	if err != nil {
+		return err
+	}
+
+	keys := crypt.NewDecrypter(provider, crypt.WithPassphrase(readPassphrase))
+	_, err = keys.PrivateKey()
+	if err != nil {
+		return err
+	}
+
+	socket := viper.GetString("agent.socket")
+	if socket == "" {
+		socket = filepath.Join(config.BaseDir(), "agent.sock")
+	}
+
+	server := agent.NewServer(newDecrypter(keys))
+	server.IdleTimeout = viper.GetDuration("agent.timeout")
+	err = server.Listen(socket)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socket)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		server.Close()
+	}()
+
+	// close stdout once the variable is printed so eval $(smithy agent &) returns
+	fmt.Printf("%s=%s; export %s;\n", agent.SocketEnv, socket, agent.SocketEnv)
+	os.Stdout.Close()
+	log.WithFields(log.Fields{"socket": socket, "key": provider.String()}).Info("agent listening")
+	return server.Serve()
+}
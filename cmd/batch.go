@@ -0,0 +1,224 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// inputFile is a file found while expanding command arguments. Rel is the
+// name used beneath an --output directory.
+type inputFile struct {
+	Path string
+	Rel  string
+}
+
+type batchResult struct {
+	file inputFile
+	err  error
+}
+
+// addBatchFlags adds the flags controlling multi-file processing,
+// binding them in viper under prefix
+func addBatchFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().IntP("jobs", "j", runtime.NumCPU(), "number of files to process concurrently")
+	viper.BindPFlag(prefix+".jobs", cmd.Flags().Lookup("jobs"))
+}
+
+// isBatch reports whether any argument names a directory or glob pattern
+func isBatch(args []string) bool {
+	for _, arg := range args {
+		if fi, err := os.Stat(arg); err == nil {
+			if fi.IsDir() {
+				return true
+			}
+		} else if strings.ContainsAny(arg, "*?[") {
+			return true
+		}
+	}
+	return false
+}
+
+// expandInputs resolves each argument as a file, a directory which is
+// walked recursively, or a glob pattern. Files found by walking a
+// directory are only included if accept returns true; hidden files and
+// directories are skipped.
+func expandInputs(args []string, accept func(string) bool) ([]inputFile, error) {
+	var files []inputFile
+	seen := make(map[string]bool)
+	add := func(path string, rel string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, inputFile{Path: path, Rel: rel})
+		}
+	}
+
+	for _, arg := range args {
+		if arg == "-" {
+			return nil, errors.New("stdin cannot be combined with other inputs")
+		}
+
+		matches := []string{arg}
+		if _, err := os.Stat(arg); err != nil {
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %s: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no such file or directory: %s", arg)
+			}
+		}
+
+		for _, match := range matches {
+			fi, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !fi.IsDir() {
+				add(match, relName(match))
+				continue
+			}
+
+			root := match
+			err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				if path != root && strings.HasPrefix(info.Name(), ".") {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if info.Mode().IsRegular() && accept(path) {
+					rel, err := filepath.Rel(root, path)
+					if err != nil {
+						return err
+					}
+					add(path, filepath.Join(relName(root), rel))
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// relName keeps relative paths that stay below the working directory and
+// falls back to the base name for everything else
+func relName(path string) string {
+	clean := filepath.Clean(path)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(os.PathSeparator)) {
+		return filepath.Base(clean)
+	}
+	return clean
+}
+
+// checkBatchTargets fails if two files would be written to the same
+// place, as happens when inputs outside the working directory share a
+// base name, so that none of them overwrites another
+func checkBatchTargets(prefix string, files []inputFile) error {
+	written := make(map[string]string, len(files))
+	for _, f := range files {
+		target, err := outputTarget(prefix, f.Path, f.Rel)
+		if err != nil {
+			return err
+		}
+		target = filepath.Clean(target)
+		if other, ok := written[target]; ok {
+			return fmt.Errorf("%s and %s would both be written to %s", other, f.Path, target)
+		}
+		written[target] = f.Path
+	}
+	return nil
+}
+
+// runBatch calls fn for every file using at most jobs concurrent workers.
+// Each result is reported on stderr and an error wrapping the first failure
+// is returned if any file failed.
+func runBatch(files []inputFile, jobs int, fn func(inputFile) error) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	work := make(chan inputFile)
+	results := make(chan batchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range work {
+				results <- batchResult{file: f, err: fn(f)}
+			}
+		}()
+	}
+
+	go func() {
+		for _, f := range files {
+			work <- f
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	var first error
+	failed := 0
+	for r := range results {
+		if r.err != nil {
+			if first == nil {
+				first = r.err
+			}
+			failed++
+			log.WithError(r.err).WithField("file", r.file.Path).Debug("file failed")
+			fmt.Fprintf(os.Stderr, "FAIL %s: %v\n", r.file.Path, r.err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "ok   %s\n", r.file.Path)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed, first error: %w", failed, len(files), first)
+	}
+	return nil
+}
+
+// checkBatchOutput makes sure results from multiple files have somewhere
+// to go and that no flag naming a single file is set
+func checkBatchOutput(prefix string) error {
+	if viper.GetString(prefix+".fileID") != "" {
+		return errors.New("--file-id names a single file, use --bind-root for several")
+	}
+	output := viper.GetString(prefix + ".output")
+	if !viper.GetBool(prefix+".inPlace") && (output == "" || output == "-") {
+		return errors.New("processing multiple files requires --in-place or an --output directory")
+	}
+	return nil
+}
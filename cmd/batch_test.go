@@ -0,0 +1,111 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// writeFiles creates empty files below dir and returns their paths
+func writeFiles(t *testing.T, dir string, names ...string) []string {
+	t.Helper()
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(path, nil, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestCheckBatchTargetsCollision(t *testing.T) {
+	dir := t.TempDir()
+	paths := writeFiles(t, dir, "a/conf", "b/conf")
+
+	defer viper.Set("encrypt.output", "")
+	viper.Set("encrypt.output", filepath.Join(dir, "out"))
+
+	// absolute inputs are written below the output by their base name
+	files, err := expandInputs(paths, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkBatchTargets("encrypt", files)
+	if err == nil || !strings.Contains(err.Error(), "would both be written to") {
+		t.Fatalf("colliding outputs were not rejected: %v", err)
+	}
+
+	// the directories keep the files apart
+	files, err = expandInputs([]string{filepath.Join(dir, "a"), filepath.Join(dir, "b")}, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkBatchTargets("encrypt", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckBatchTargetsDistinct(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	writeFiles(t, ".", "a/conf", "b/conf")
+
+	defer viper.Set("encrypt.output", "")
+	viper.Set("encrypt.output", "out")
+
+	files, err := expandInputs([]string{"a/conf", "b/conf", "a"}, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkBatchTargets("encrypt", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// in place every file is its own target
+	viper.Set("encrypt.output", "")
+	defer viper.Set("encrypt.inPlace", false)
+	viper.Set("encrypt.inPlace", true)
+	files, err = expandInputs([]string{filepath.Join(dir, "a/conf"), filepath.Join(dir, "b/conf")}, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = checkBatchTargets("encrypt", files)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
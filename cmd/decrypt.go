@@ -15,13 +15,18 @@
 package cmd
 
 import (
+	"bytes"
 	"errors"
-	"path/filepath"
-
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/agent"
 	"github.com/mshindle/smithy/config"
+	"github.com/mshindle/smithy/crypt"
 	"github.com/mshindle/smithy/data"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -29,61 +34,211 @@ import (
 
 // decryptCmd represents the decrypt command
 var decryptCmd = &cobra.Command{
-	Use:     "decrypt",
-	Short:   "decrypt a string or file with a private key",
-	Long:    `decrypt a string or file with a private key`,
-	PreRunE: preDecrypt,
-	Run:     runDecrypt,
+	Use:   "decrypt [file|dir|pattern...]",
+	Short: "decrypt a string or file with a private key",
+	Long: `
+decrypt a string or file with a private key. When no file is given,
+or the file is "-", the data is read from stdin. The data format is
+taken from --input-format, then the file extension, and finally
+detected from the content itself.
+
+Directories are searched recursively for files in a known format and
+glob patterns are expanded. Multiple files are decrypted concurrently
+and need either --in-place or an --output directory.
+
+The private key is read from --key, the SMITHY_PRIVATE_KEY environment
+variable (PEM content), or the privateKey setting, in that order. When
+SMITHY_AUTH_SOCK points at a running agent and --key is not given, the
+agent decrypts the values instead.
+
+Values bound to their file need the same file identity they were
+encrypted with, given by --file-id or --bind-root as for encrypt.
+
+A document MAC in the smithy metadata block is verified before anything
+is decrypted and the block is left out of the output.`,
+	PreRunE:      preDecrypt,
+	RunE:         runDecrypt,
+	SilenceUsage: true,
 }
 
 func init() {
 	RootCmd.AddCommand(decryptCmd)
 	decryptCmd.Flags().StringP("label", "l", "label", "label to use for each encrypted string")
 	decryptCmd.Flags().BoolP("string", "s", false, "decrypt args as a string instead of a file")
+	decryptCmd.Flags().String("input-format", "", "format of the input data ("+strings.Join(data.Formats(), ", ")+")")
+	decryptCmd.Flags().StringP("key", "k", "", "private key source: a file, env:NAME, stdin, fd:N or cmd:COMMAND")
+	decryptCmd.Flags().Bool("require-binding", false, "reject values not bound to their field path")
+	decryptCmd.Flags().String("file-id", "", "identity of the file for values bound to their file")
+	decryptCmd.Flags().String("bind-root", "", "identify files by their path relative to this directory for values bound to their file")
+	decryptCmd.Flags().Bool("allow-whitespace", false, "accept whitespace around and inside encrypted values, such as wrapped base64")
+	decryptCmd.Flags().Bool("require-mac", false, "reject documents without a valid document MAC")
+	decryptCmd.Flags().Bool("require-signature", false, "reject files not signed by one of the trustedSigners")
 	viper.BindPFlag("decrypt.label", decryptCmd.Flags().Lookup("label"))
 	viper.BindPFlag("decrypt.string", decryptCmd.Flags().Lookup("string"))
+	viper.BindPFlag("decrypt.inputFormat", decryptCmd.Flags().Lookup("input-format"))
+	viper.BindPFlag("decrypt.key", decryptCmd.Flags().Lookup("key"))
+	viper.BindPFlag("decrypt.requireBinding", decryptCmd.Flags().Lookup("require-binding"))
+	viper.BindPFlag("decrypt.fileID", decryptCmd.Flags().Lookup("file-id"))
+	viper.BindPFlag("decrypt.bindRoot", decryptCmd.Flags().Lookup("bind-root"))
+	viper.BindPFlag("decrypt.allowWhitespace", decryptCmd.Flags().Lookup("allow-whitespace"))
+	viper.BindPFlag("decrypt.requireMAC", decryptCmd.Flags().Lookup("require-mac"))
+	viper.BindPFlag("decrypt.requireSignature", decryptCmd.Flags().Lookup("require-signature"))
+	addOutputFlags(decryptCmd, "decrypt")
+	addBatchFlags(decryptCmd, "decrypt")
 }
 
 func preDecrypt(cmd *cobra.Command, args []string) error {
 	argAsString = viper.GetBool("string")
-	if len(args) != 1 {
-		return errors.New("only one argument allowed for preDecrypt")
-	}
-	// determine format of input file
-	ext := filepath.Ext(args[0])
-	switch ext {
-	case ".json":
-		processor = data.NewJsonProcessor()
-	case ".yaml", ".yml":
-		processor = data.NewYamlProcessor()
-	default:
-		log.WithField("format", ext).Fatal("unsupported format")
-		return errors.New("unsupported format")
-	}
 	return nil
 }
 
-func runDecrypt(cmd *cobra.Command, args []string) {
-	object, err := processor.UnmarshalFile(args[0])
+func runDecrypt(cmd *cobra.Command, args []string) error {
+	var decrypter crypt.Decrypter
+	provider, err := privateKeyProvider("decrypt.key")
+	if err != nil {
+		return err
+	}
+
+	if socket := os.Getenv(agent.SocketEnv); socket != "" && viper.GetString("decrypt.key") == "" {
+		log.WithField("socket", socket).Debug("decrypting with agent")
+		decrypter = agent.NewClient(socket)
+	} else {
+		decrypter = newDecrypter(crypt.NewDecrypter(provider, crypt.WithPassphrase(readPassphrase)))
+	}
+
+	if len(args) == 0 || (len(args) == 1 && !isBatch(args)) {
+		file := "-"
+		if len(args) == 1 {
+			file = args[0]
+		}
+		if file == "-" && provider.String() == "stdin" {
+			return errors.New("cannot read both the key and the data from stdin")
+		}
+		return decryptFile(file, "", decrypter)
+	}
+
+	err = checkBatchOutput("decrypt")
 	if err != nil {
-		log.WithError(err).WithField("file", args[0]).Error("cannot unmarshal and decrypt file")
-		return
+		return err
 	}
 
-	err = object.DecryptValues(viper.GetString("decrypt.label"), config.PrivateKey())
+	files, err := expandInputs(args, func(path string) bool {
+		return data.FormatFromExt(filepath.Ext(path)) != ""
+	})
 	if err != nil {
-		log.WithError(err).WithField("object", object).Error("cannot decrypt object")
-		return
+		return err
+	}
+	err = checkBatchTargets("decrypt", files)
+	if err != nil {
+		return err
+	}
+
+	return runBatch(files, viper.GetInt("decrypt.jobs"), func(f inputFile) error {
+		return decryptFile(f.Path, f.Rel, decrypter)
+	})
+}
+
+// privateKeyProvider returns the source of the private key, taken from
+// the --key flag bound to flagKey, then SMITHY_PRIVATE_KEY, then the
+// privateKey setting
+func privateKeyProvider(flagKey string) (crypt.KeyProvider, error) {
+	spec := viper.GetString(flagKey)
+	if spec == "" && os.Getenv(crypt.PrivateKeyEnv) != "" {
+		spec = "env:" + crypt.PrivateKeyEnv
 	}
+	if spec == "" {
+		spec = config.PrivateKey()
+	}
+	return crypt.ParseKeyProvider(spec)
+}
+
+// decryptFile decrypts all encrypted values in file and writes out the result
+func decryptFile(file string, rel string, decrypter crypt.Decrypter) error {
+	logger := log.WithField("file", file)
 
-	b, err := processor.Marshal(object)
+	b, err := readInput(file)
 	if err != nil {
-		log.WithError(err).Error("cannot marshal decrypted file")
-		return
+		logger.WithError(err).Debug("cannot read input")
+		return err
 	}
 
-	_, err = b.WriteTo(os.Stdout)
+	p, err := selectProcessor(viper.GetString("decrypt.inputFormat"), file, b)
 	if err != nil {
-		log.WithError(err).Error("cannot write out data")
+		logger.WithError(err).Debug("cannot determine input format")
+		return err
+	}
+
+	object, err := p.Decode(bytes.NewReader(b))
+	if err != nil {
+		logger.WithError(err).Debug("cannot unmarshal and decrypt file")
+		return err
+	}
+
+	if viper.GetBool("decrypt.requireSignature") {
+		signers, err := trustedSigners()
+		if err != nil {
+			return err
+		}
+		signer, err := object.VerifyFile(file, signers)
+		if err != nil {
+			logger.WithError(err).Debug("cannot verify signature")
+			return data.InFile(err, file)
+		}
+		logger.WithField("signer", signer.Name).Debug("signature verified")
+	}
+
+	label := viper.GetString("decrypt.label")
+	if object.HasMAC() || viper.GetBool("decrypt.requireMAC") {
+		err = object.VerifyMAC(decrypter, label)
+		if err != nil {
+			logger.WithError(err).Debug("cannot verify document MAC")
+			return data.InFile(err, file)
+		}
+	}
+
+	opts := data.DecryptOptions{
+		Label:           label,
+		RequireBinding:  viper.GetBool("decrypt.requireBinding"),
+		AllowWhitespace: viper.GetBool("decrypt.allowWhitespace"),
+	}
+	opts.File, err = fileID("decrypt", file)
+	if err != nil {
+		// only values bound to their file need it, and they fail without it
+		logger.WithError(err).Debug("file has no identity")
+	}
+	err = object.DecryptValuesWith(decrypter, opts)
+	if err != nil {
+		logger.WithError(err).Debug("cannot decrypt object")
+		return data.InFile(err, file)
+	}
+	object.RemoveMetadata()
+
+	err = writeOutput("decrypt", file, rel, 0600, false, func(w io.Writer) error {
+		return p.Encode(w, object)
+	})
+	if err != nil {
+		logger.WithError(err).Debug("cannot write out decrypted data")
+	}
+	return err
+}
+
+// readInput returns the contents of file, reading stdin when file is "-"
+func readInput(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(file)
+}
+
+// selectProcessor picks the processor for the input using an explicit
+// format if given, then the file extension, then sniffing the content.
+func selectProcessor(format string, file string, b []byte) (data.Processor, error) {
+	if format == "" {
+		format = data.FormatFromExt(filepath.Ext(file))
+	}
+	if format == "" {
+		format = data.Detect(b)
+		log.WithFields(log.Fields{"file": file, "format": format}).Debug("detected input format")
 	}
+	return data.NewProcessor(format)
 }
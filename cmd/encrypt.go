@@ -16,11 +16,12 @@ package cmd
 
 import (
 	"errors"
+	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/mshindle/smithy/config"
 	"github.com/mshindle/smithy/crypt"
 	"github.com/mshindle/smithy/data"
 	"github.com/spf13/cobra"
@@ -29,70 +30,217 @@ import (
 
 // encryptCmd represents the encrypt command
 var encryptCmd = &cobra.Command{
-	Use:   "encrypt",
+	Use:   "encrypt [file|dir|pattern...]",
 	Short: "encrypts a string or file with a public key",
-	Long:  `encrypts a string or file with a public key`,
-	PreRun: func(cmd *cobra.Command, args []string) {
+	Long: `
+encrypts a string or file with a public key. Directories and glob
+patterns encrypt every file found separately, processing them
+concurrently; this needs either --in-place or an --output directory.
+
+With --path the value is stored at that dotted field path and bound to
+it, so it fails to decrypt if copied to another field. It is also bound
+to a file identity, so it fails to decrypt in another file, when one is
+given with --file-id or the output file lies below --bind-root (or the
+bindRoot setting); the identity is then its path relative to that root.
+
+With encryptMethod siv values are encrypted deterministically under the
+data key in the sivKey file, which is unwrapped with the private key
+given by --key, SMITHY_PRIVATE_KEY or the privateKey setting.`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
 		argAsString = viper.GetBool("string")
-		switch viper.GetString("format") {
-		case "yaml", "yml":
-			processor = data.NewYamlProcessor()
-		default:
-			log.WithField("format", viper.GetString("format")).Fatal("unsupported format")
-		}
+		processor, err = data.NewProcessor(viper.GetString("format"))
+		return err
 	},
-	Run: encrypt,
+	RunE:         encrypt,
+	SilenceUsage: true,
 }
 
 func init() {
 	RootCmd.AddCommand(encryptCmd)
 	encryptCmd.Flags().StringP("label", "l", "label", "label to use for each encrypted string")
 	encryptCmd.Flags().BoolP("string", "s", false, "encrypt args as a string instead of a file")
-	encryptCmd.Flags().StringP("format", "f", "yaml", "output data format (default: yaml)")
+	encryptCmd.Flags().StringP("format", "f", "yaml", "output data format ("+strings.Join(data.Formats(), ", ")+")")
+	encryptCmd.Flags().StringP("recipient", "r", "", "public key to encrypt to: PEM or OpenSSH (ssh-rsa, ssh-ed25519) format, or an age recipients file")
+	encryptCmd.Flags().StringSlice("pgp", nil, "encrypt with OpenPGP to the key with this fingerprint in pgp.keyring (repeatable)")
+	encryptCmd.Flags().String("cert", "", "encrypt to the RSA or ECDSA key of this PEM X.509 certificate")
+	encryptCmd.Flags().String("path", "", "store the value at this dotted field path and bind it to the path")
+	encryptCmd.Flags().String("file-id", "", "with --path, also bind the value to this file identity")
+	encryptCmd.Flags().String("bind-root", "", "with --path, also bind the value to the output path relative to this directory")
+	encryptCmd.Flags().Bool("mac", false, "add a document MAC covering every value to the output (siv or vault only)")
+	encryptCmd.Flags().StringP("key", "k", "", "private key unwrapping the siv data key: a file, env:NAME, stdin, fd:N or cmd:COMMAND")
 	viper.BindPFlag("label", encryptCmd.Flags().Lookup("label"))
 	viper.BindPFlag("string", encryptCmd.Flags().Lookup("string"))
 	viper.BindPFlag("format", encryptCmd.Flags().Lookup("format"))
+	viper.BindPFlag("encrypt.recipient", encryptCmd.Flags().Lookup("recipient"))
+	viper.BindPFlag("encrypt.pgp", encryptCmd.Flags().Lookup("pgp"))
+	viper.BindPFlag("encrypt.cert", encryptCmd.Flags().Lookup("cert"))
+	viper.BindPFlag("encrypt.path", encryptCmd.Flags().Lookup("path"))
+	viper.BindPFlag("encrypt.fileID", encryptCmd.Flags().Lookup("file-id"))
+	viper.BindPFlag("encrypt.bindRoot", encryptCmd.Flags().Lookup("bind-root"))
+	viper.BindPFlag("encrypt.mac", encryptCmd.Flags().Lookup("mac"))
+	viper.BindPFlag("encrypt.key", encryptCmd.Flags().Lookup("key"))
+	addOutputFlags(encryptCmd, "encrypt")
+	addBatchFlags(encryptCmd, "encrypt")
 }
 
-func encrypt(cmd *cobra.Command, args []string) {
+func encrypt(cmd *cobra.Command, args []string) error {
 	var d [][]byte
 	var err error
-	var encryptedValues = make(map[string]interface{})
+	var encryptedValues = make(data.Object)
 	var label = viper.GetString("label")
 
+	if !argAsString && (isBatch(args) || (viper.GetBool("encrypt.inPlace") && len(args) > 1)) {
+		return encryptFiles(args)
+	}
+
+	encrypter, err := newEncrypter()
+	if err != nil {
+		return err
+	}
+
 	d, err = parseEncryptArgs(args)
 	if err != nil {
-		log.WithError(err).Error("could not encrypt args")
-		return
+		log.WithError(err).Debug("could not encrypt args")
+		return err
 	}
 
-	if len(d) == 1 {
-		encryptedValues[label], err = crypt.EncryptToString(d[0], label, config.PublicKey())
+	if path := viper.GetString("encrypt.path"); path != "" {
+		if len(d) != 1 {
+			return errors.New("--path binds a single value")
+		}
+		binding, err := encryptBinding(singleInput(args), "")
+		if err != nil {
+			return err
+		}
+		s, err := crypt.EncryptBound(encrypter, d[0], label, binding)
 		if err != nil {
-			log.WithError(err).Fatal("encryption failed")
-			return
+			log.WithError(err).Debug("encryption failed")
+			return err
+		}
+		encryptedValues.SetPath(path, s)
+	} else if len(d) == 1 {
+		encryptedValues[label], err = crypt.EncryptWith(encrypter, d[0], label)
+		if err != nil {
+			log.WithError(err).Debug("encryption failed")
+			return err
 		}
 	} else {
-		strings := make([]string, len(d))
+		values := make([]string, len(d))
 		for i := range d {
-			strings[i], err = crypt.EncryptToString(d[i], label, config.PublicKey())
+			values[i], err = crypt.EncryptWith(encrypter, d[i], label)
 			if err != nil {
-				log.WithError(err).Fatal("encryption failed")
-				return
+				log.WithError(err).Debug("encryption failed")
+				return err
 			}
-			encryptedValues[label] = strings
+			encryptedValues[label] = values
+		}
+	}
+
+	if viper.GetBool("encrypt.mac") {
+		target, err := outputTarget("encrypt", singleInput(args), "")
+		if err != nil {
+			return err
 		}
+		err = addMAC(processor, encryptedValues, encrypter, label, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeOutput("encrypt", singleInput(args), "", 0644, true, func(w io.Writer) error {
+		return processor.Encode(w, encryptedValues)
+	})
+	if err != nil {
+		log.WithError(err).Debug("cannot write out data")
 	}
+	return err
+}
 
-	buffer, err := processor.Marshal(encryptedValues)
+// encryptBinding returns where a value encrypted with --path is bound:
+// the path, and the identity of the output file if one is known
+func encryptBinding(input string, rel string) (crypt.Binding, error) {
+	binding := crypt.Binding{Path: viper.GetString("encrypt.path")}
+
+	target, err := outputTarget("encrypt", input, rel)
 	if err != nil {
-		log.WithError(err).Error("cannot marshal data")
-		return
+		return binding, err
 	}
-	_, err = buffer.WriteTo(os.Stdout)
+	binding.File, err = fileID("encrypt", target)
+	return binding, err
+}
+
+// encryptFiles encrypts the contents of each file found in args separately
+func encryptFiles(args []string) error {
+	err := checkBatchOutput("encrypt")
 	if err != nil {
-		log.WithError(err).Error("cannot write out data")
+		return err
+	}
+
+	files, err := expandInputs(args, func(string) bool { return true })
+	if err != nil {
+		return err
+	}
+	err = checkBatchTargets("encrypt", files)
+	if err != nil {
+		return err
+	}
+
+	encrypter, err := newEncrypter()
+	if err != nil {
+		return err
+	}
+
+	label := viper.GetString("label")
+	path := viper.GetString("encrypt.path")
+	return runBatch(files, viper.GetInt("encrypt.jobs"), func(f inputFile) error {
+		b, err := ioutil.ReadFile(f.Path)
+		if err != nil {
+			return err
+		}
+
+		object := make(data.Object)
+		if path != "" {
+			binding, err := encryptBinding(f.Path, f.Rel)
+			if err != nil {
+				return err
+			}
+			s, err := crypt.EncryptBound(encrypter, b, label, binding)
+			if err != nil {
+				return err
+			}
+			object.SetPath(path, s)
+		} else {
+			s, err := crypt.EncryptWith(encrypter, b, label)
+			if err != nil {
+				return err
+			}
+			object[label] = s
+		}
+
+		if viper.GetBool("encrypt.mac") {
+			target, err := outputTarget("encrypt", f.Path, f.Rel)
+			if err != nil {
+				return err
+			}
+			err = addMAC(processor, object, encrypter, label, target)
+			if err != nil {
+				return err
+			}
+		}
+
+		return writeOutput("encrypt", f.Path, f.Rel, 0644, true, func(w io.Writer) error {
+			return processor.Encode(w, object)
+		})
+	})
+}
+
+// singleInput returns the file being encrypted, or "" if the input is not a single file
+func singleInput(args []string) string {
+	if argAsString || len(args) != 1 {
+		return ""
 	}
+	return args[0]
 }
 
 func parseEncryptArgs(args []string) ([][]byte, error) {
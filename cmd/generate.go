@@ -17,6 +17,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/mshindle/smithy/config"
@@ -35,7 +36,12 @@ var generateCmd = &cobra.Command{
 Smithy generates a public/private key pair for use in encrypting/decrypting fields.
 The keys will be written to the files identified by the publicKey & privateKey
 configuration fields. The default names are public_key.pem and private_key.pem.
-Unless absolute paths are specified, the keys will be written into the baseDir.`,
+Unless absolute paths are specified, the keys will be written into the baseDir.
+The --algorithm flag picks RSA (the default), an elliptic curve key on
+NIST P-256 or P-384, which encrypts values with ECIES, or an Ed25519 key,
+suited to signing documents.
+With --passphrase the private key is encrypted with a passphrase, read from
+SMITHY_KEY_PASSPHRASE or asked for on the terminal.`,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		log.WithField("force", viper.GetBool(force)).Info("overwriting of existing key files")
 	},
@@ -47,17 +53,35 @@ Unless absolute paths are specified, the keys will be written into the baseDir.`
 			return
 		}
 
-		err := crypt.Generate(pubFile, privateFile)
+		var passphrase []byte
+		var err error
+		if viper.GetBool("generate.passphrase") {
+			passphrase, err = newPassphrase()
+			if err != nil {
+				log.WithError(err).Fatal("could not read passphrase")
+			}
+		}
+
+		err = crypt.Generate(pubFile, privateFile, viper.GetString("generate.algorithm"), passphrase)
 		if err != nil {
 			log.WithError(err).Fatal("could not generate keys")
 		}
+		log.WithFields(log.Fields{
+			"privfile":  privateFile,
+			"pubfile":   pubFile,
+			"algorithm": viper.GetString("generate.algorithm"),
+		}).Info("all key files written")
 	},
 }
 
 func init() {
 	RootCmd.AddCommand(generateCmd)
 	generateCmd.Flags().BoolP(force, "f", false, "overwrite existing keys")
+	generateCmd.Flags().Bool("passphrase", false, "protect the private key with a passphrase")
 	viper.BindPFlag(force, generateCmd.Flags().Lookup(force))
+	generateCmd.Flags().String("algorithm", crypt.AlgorithmRSA, "key algorithm ("+strings.Join(crypt.Algorithms, ", ")+")")
+	viper.BindPFlag("generate.passphrase", generateCmd.Flags().Lookup("passphrase"))
+	viper.BindPFlag("generate.algorithm", generateCmd.Flags().Lookup("algorithm"))
 }
 
 func checkExists(file string) bool {
@@ -0,0 +1,280 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/config"
+	"github.com/mshindle/smithy/crypt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// keysCmd groups the commands managing key files
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "manage smithy key files",
+}
+
+// passwdCmd changes the passphrase of the private key
+var passwdCmd = &cobra.Command{
+	Use:   "passwd [private key file]",
+	Short: "change the passphrase protecting a private key",
+	Long: `
+passwd changes the passphrase protecting a private key, defaulting to the
+privateKey setting. The current passphrase is taken from
+SMITHY_KEY_PASSPHRASE or asked for if the key is protected; the new one is
+always asked for on the terminal. An empty new passphrase stores the key
+unprotected. OpenSSH keys are refused, as the key would be rewritten in a
+format ssh cannot read; use ssh-keygen -p for them.`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runPasswd,
+	SilenceUsage: true,
+}
+
+// dataKeyCmd creates the data key for deterministic encryption
+var dataKeyCmd = &cobra.Command{
+	Use:   "datakey [file]",
+	Short: "create the data key used by the siv encryptMethod",
+	Long: `
+datakey creates a random AES-SIV data key and saves it, wrapped for each
+--recipient, in the given file or the sivKey setting. Without
+--recipient the key is wrapped for the publicKey setting. Anyone holding
+the private key of one of the recipients can encrypt and decrypt values
+with encryptMethod siv.
+
+Deterministic encryption lets anyone compare values without decrypting
+them: equal plaintexts under the same field path encrypt the same way.`,
+	Args:         cobra.MaximumNArgs(1),
+	RunE:         runDataKey,
+	SilenceUsage: true,
+}
+
+// splitCmd splits the private key into shares
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "split the private key into shares, a threshold of which rebuild it",
+	Long: `
+split divides the private key from --key, SMITHY_PRIVATE_KEY or the
+privateKey setting into --shares shares with Shamir's secret sharing. Any
+--threshold of them rebuild the key, while fewer reveal nothing about it.
+The shares are printed as PEM blocks, suited to printing on paper, or
+written to share-N.pem files in --output-dir.
+
+Decrypt with a threshold of shares using --key shares:FILE,FILE,... or by
+passing them concatenated with --key stdin; the key is only rebuilt in
+memory. keys combine writes the rebuilt key out.`,
+	Args:         cobra.NoArgs,
+	RunE:         runSplit,
+	SilenceUsage: true,
+}
+
+// combineCmd rebuilds the private key from its shares
+var combineCmd = &cobra.Command{
+	Use:   "combine [share file...]",
+	Short: "rebuild a private key from a threshold of its shares",
+	Long: `
+combine rebuilds a private key split with keys split from the shares in
+the given files, or read from stdin. The key is printed as PEM, or saved
+to --output, encrypted with a new passphrase if --passphrase is set.`,
+	RunE:         runCombine,
+	SilenceUsage: true,
+}
+
+func init() {
+	RootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(splitCmd)
+	keysCmd.AddCommand(combineCmd)
+	splitCmd.Flags().StringP("key", "k", "", "private key source: a file, env:NAME, stdin, fd:N or cmd:COMMAND")
+	splitCmd.Flags().Int("shares", 5, "number of shares to create")
+	splitCmd.Flags().Int("threshold", 3, "number of shares needed to rebuild the key")
+	splitCmd.Flags().String("output-dir", "", "write each share to share-N.pem in this directory")
+	viper.BindPFlag("split.key", splitCmd.Flags().Lookup("key"))
+	combineCmd.Flags().StringP("output", "o", "", "save the key to this file instead of printing it")
+	combineCmd.Flags().Bool("passphrase", false, "protect the saved key with a passphrase")
+	keysCmd.AddCommand(passwdCmd)
+	keysCmd.AddCommand(dataKeyCmd)
+	dataKeyCmd.Flags().StringSliceP("recipient", "r", nil, "public key or certificate to wrap the data key for (repeatable)")
+	dataKeyCmd.Flags().Bool("force", false, "replace an existing data key")
+}
+
+func runPasswd(cmd *cobra.Command, args []string) error {
+	file := config.PrivateKey()
+	if len(args) == 1 {
+		file = args[0]
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if crypt.IsOpenSSHKey(b) {
+		return fmt.Errorf("%s is an OpenSSH key, change its passphrase with ssh-keygen -p -f %s", file, file)
+	}
+
+	key, err := crypt.ParsePrivateKey(b)
+	if errors.Is(err, crypt.ErrPassphraseRequired) {
+		var current []byte
+		current, err = readPassphrase(file)
+		if err != nil {
+			return err
+		}
+		key, err = crypt.ParsePrivateKeyWithPassphrase(b, current)
+	}
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := confirmPassphrase()
+	if err != nil {
+		return err
+	}
+	err = crypt.WritePrivateKey(file, key, passphrase)
+	if err == nil {
+		log.WithFields(log.Fields{"file": file, "protected": len(passphrase) > 0}).Info("private key written")
+	}
+	return err
+}
+
+func runDataKey(cmd *cobra.Command, args []string) error {
+	file := config.SIVKey()
+	if len(args) == 1 {
+		file = args[0]
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if _, err := os.Stat(file); err == nil && !force {
+		return fmt.Errorf("data key %s already exists, use --force to replace it", file)
+	}
+
+	recipients, _ := cmd.Flags().GetStringSlice("recipient")
+	if len(recipients) == 0 {
+		recipients = []string{config.PublicKey()}
+	}
+	encrypters := make([]crypt.Encrypter, len(recipients))
+	for i, r := range recipients {
+		e, err := crypt.LoadEncrypter(r)
+		if err != nil {
+			return err
+		}
+		encrypters[i] = e
+	}
+
+	err := crypt.GenerateDataKey(file, encrypters...)
+	if err == nil {
+		log.WithFields(log.Fields{"file": file, "recipients": len(recipients)}).Info("data key written")
+	}
+	return err
+}
+
+func runSplit(cmd *cobra.Command, args []string) error {
+	provider, err := privateKeyProvider("split.key")
+	if err != nil {
+		return err
+	}
+	key, err := crypt.NewDecrypter(provider, crypt.WithPassphrase(readPassphrase)).PrivateKey()
+	if err != nil {
+		return err
+	}
+
+	count, _ := cmd.Flags().GetInt("shares")
+	threshold, _ := cmd.Flags().GetInt("threshold")
+	shares, err := crypt.SplitPrivateKey(key, count, threshold)
+	if err != nil {
+		return err
+	}
+
+	dir, _ := cmd.Flags().GetString("output-dir")
+	for i, share := range shares {
+		if dir == "" {
+			if i > 0 {
+				fmt.Println()
+			}
+			os.Stdout.Write(share.MarshalPEM())
+			continue
+		}
+
+		file := filepath.Join(dir, fmt.Sprintf("share-%d.pem", share.Index))
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("cannot overwrite existing share %s", file)
+		}
+		err = writeFileAtomic(file, 0600, func(w io.Writer) error {
+			_, err := w.Write(share.MarshalPEM())
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	log.WithFields(log.Fields{"key": provider, "shares": count, "threshold": threshold}).Info("private key split")
+	return nil
+}
+
+func runCombine(cmd *cobra.Command, args []string) error {
+	var provider crypt.KeyProvider = crypt.StdinKey()
+	if len(args) > 0 {
+		shares := make(crypt.SharesKey, len(args))
+		for i, f := range args {
+			shares[i] = crypt.FileKey(f)
+		}
+		provider = shares
+	}
+
+	b, err := provider.KeyBytes()
+	if err != nil {
+		return err
+	}
+	shares, err := crypt.ParseKeyShares(b)
+	if err != nil {
+		return err
+	}
+	key, err := crypt.CombineKeyShares(shares)
+	if err != nil {
+		return err
+	}
+
+	file, _ := cmd.Flags().GetString("output")
+	if file == "" {
+		pem, err := crypt.MarshalPrivateKey(key, nil)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(pem)
+		return err
+	}
+
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("cannot overwrite existing key %s", file)
+	}
+	var passphrase []byte
+	if protect, _ := cmd.Flags().GetBool("passphrase"); protect {
+		passphrase, err = newPassphrase()
+		if err != nil {
+			return err
+		}
+	}
+	err = crypt.WritePrivateKey(file, key, passphrase)
+	if err == nil {
+		log.WithFields(log.Fields{"file": file, "protected": len(passphrase) > 0}).Info("private key written")
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// macCmd (re)computes the document MAC of files
+var macCmd = &cobra.Command{
+	Use:   "mac file...",
+	Short: "add or update the document MAC of encrypted files",
+	Long: `
+mac computes a MAC over every value of each file, plaintext and encrypted
+alike, and stores it in the smithy metadata block so that decrypt detects
+any later change. Run it again after editing a file on purpose.
+
+A file without a MAC gets one under a new key encrypted with the
+encryptMethod, which must be siv or vault: a key encrypted to a public
+key would let anyone holding it forge the MAC. A file that has one keeps
+its key, which is decrypted with --key as for decrypt; --new-key
+replaces it instead.`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runMAC,
+	SilenceUsage: true,
+}
+
+func init() {
+	RootCmd.AddCommand(macCmd)
+	macCmd.Flags().StringP("label", "l", "label", "label the values were encrypted with")
+	macCmd.Flags().StringP("key", "k", "", "private key source: a file, env:NAME, stdin, fd:N or cmd:COMMAND")
+	macCmd.Flags().Bool("new-key", false, "compute the MAC with a new key")
+	viper.BindPFlag("mac.label", macCmd.Flags().Lookup("label"))
+	viper.BindPFlag("mac.key", macCmd.Flags().Lookup("key"))
+	viper.BindPFlag("mac.newKey", macCmd.Flags().Lookup("new-key"))
+}
+
+func runMAC(cmd *cobra.Command, args []string) error {
+	label := viper.GetString("mac.label")
+	for _, file := range args {
+		p, object, err := readObject(file)
+		if err != nil {
+			return err
+		}
+
+		if object.HasMAC() && !viper.GetBool("mac.newKey") {
+			var provider crypt.KeyProvider
+			provider, err = privateKeyProvider("mac.key")
+			if err != nil {
+				return err
+			}
+			err = object.UpdateMAC(newDecrypter(crypt.NewDecrypter(provider, crypt.WithPassphrase(readPassphrase))), label)
+		} else {
+			var encrypter crypt.Encrypter
+			encrypter, err = newEncrypter()
+			if err != nil {
+				return err
+			}
+			err = addMAC(p, object, encrypter, label, "")
+		}
+		if err != nil {
+			return data.InFile(err, file)
+		}
+
+		err = writeFileAtomic(file, keepMode(file, 0644), func(w io.Writer) error {
+			return p.Encode(w, object)
+		})
+		if err != nil {
+			return err
+		}
+		log.WithField("file", file).Info("document MAC written")
+	}
+	return nil
+}
+
+// addMAC adds a document MAC under a new key encrypted with e. The MAC
+// key of previous, the file about to be replaced, is reused when e can
+// decrypt it, so encrypting the same input again with siv gives the same
+// file.
+func addMAC(p data.Processor, object data.Object, e crypt.Encrypter, label string, previous string) error {
+	if _, ok := p.(*data.DotenvProcessor); ok {
+		return errors.New("dotenv files cannot hold the smithy metadata block")
+	}
+
+	if r, ok := e.(interface{ Decrypter() crypt.Decrypter }); ok && previous != "" {
+		err := reuseMACKey(object, r.Decrypter(), label, previous)
+		if err == nil {
+			return nil
+		}
+		log.WithError(err).WithField("file", previous).Debug("cannot reuse MAC key, creating a new one")
+	}
+	return object.AddMAC(e, label)
+}
+
+// reuseMACKey computes the MAC of object with the MAC key of file
+func reuseMACKey(object data.Object, d crypt.Decrypter, label string, file string) error {
+	if _, err := os.Stat(file); err != nil {
+		return err
+	}
+	_, previous, err := readObject(file)
+	if err != nil {
+		return err
+	}
+	ok, err := object.CopyMACKey(previous)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return data.ErrNoMAC
+	}
+	return object.UpdateMAC(d, label)
+}
@@ -0,0 +1,160 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/agent"
+	"github.com/mshindle/smithy/config"
+	"github.com/mshindle/smithy/crypt"
+	"github.com/spf13/viper"
+)
+
+// encryptMethod names accepted in the encryptMethod setting
+const (
+	methodRSA   = "rsa"
+	methodVault = "vault"
+	methodAge   = "age"
+	methodPGP   = "pgp"
+	methodSIV   = "siv"
+)
+
+// newEncrypter returns the Encrypter for the configured encryptMethod.
+// Keys given with --pgp or --cert are used whatever the method.
+func newEncrypter() (crypt.Encrypter, error) {
+	if fingerprints := viper.GetStringSlice("encrypt.pgp"); len(fingerprints) > 0 {
+		return pgpEncrypter(fingerprints)
+	}
+	if cert := viper.GetString("encrypt.cert"); cert != "" {
+		e, err := crypt.LoadCertEncrypter(cert)
+		if err != nil {
+			return nil, err
+		}
+		warnCertificate(cert, e)
+		return e, nil
+	}
+
+	switch config.EncryptMethod() {
+	case methodRSA, "":
+		e, err := crypt.LoadEncrypter(publicKeyFile())
+		if ce, ok := e.(*crypt.CertEncrypter); ok {
+			warnCertificate(publicKeyFile(), ce)
+		}
+		return e, err
+	case methodAge:
+		return crypt.LoadAgeEncrypter(publicKeyFile())
+	case methodPGP:
+		return pgpEncrypter(config.PGP().Recipients)
+	case methodSIV:
+		unwrap, err := dataKeyDecrypter()
+		if err != nil {
+			return nil, err
+		}
+		return crypt.NewSIVEncrypter(crypt.NewDataKey(config.SIVKey(), unwrap)), nil
+	case methodVault:
+		v, err := vaultTransit()
+		if err != nil {
+			return nil, err
+		}
+		return crypt.NewKMSEncrypter(v), nil
+	}
+	return nil, fmt.Errorf("unsupported encryptMethod %q", config.EncryptMethod())
+}
+
+// dataKeyDecrypter returns the Decrypter unwrapping the siv data key for
+// encryption: the agent if one is running and --key is not given, else
+// the private key
+func dataKeyDecrypter() (crypt.Decrypter, error) {
+	if socket := os.Getenv(agent.SocketEnv); socket != "" && viper.GetString("encrypt.key") == "" {
+		return agent.NewClient(socket), nil
+	}
+	provider, err := privateKeyProvider("encrypt.key")
+	if err != nil {
+		return nil, err
+	}
+	return newDecrypter(crypt.NewDecrypter(provider, crypt.WithPassphrase(readPassphrase))), nil
+}
+
+// warnCertificate logs why the certificate being encrypted to is unfit
+func warnCertificate(file string, e *crypt.CertEncrypter) {
+	for _, w := range e.Warnings(time.Now()) {
+		log.WithFields(log.Fields{"cert": file, "subject": e.Certificate.Subject.String()}).Warn(w)
+	}
+}
+
+// publicKeyFile returns the recipient given with --recipient, falling
+// back to the publicKey setting
+func publicKeyFile() string {
+	if file := viper.GetString("encrypt.recipient"); file != "" {
+		return file
+	}
+	return config.PublicKey()
+}
+
+// newDecrypter returns a Decrypter for every method which has keys configured
+func newDecrypter(keys *crypt.KeyDecrypter) crypt.Decrypter {
+	m := crypt.MethodDecrypter{
+		crypt.MethodRSA:   keys,
+		crypt.MethodECIES: keys,
+		crypt.MethodAge:   keys,
+	}
+	if v, err := vaultTransit(); err == nil {
+		m[crypt.MethodKMS] = crypt.NewKMSDecrypter(v)
+	}
+	m[crypt.MethodPGP] = keys
+	if secret := config.PGP().SecretKeyring; secret != "" {
+		m[crypt.MethodPGP] = crypt.NewDecrypter(crypt.FileKey(secret), crypt.WithPassphrase(readPassphrase))
+	}
+
+	// the data key is unwrapped with any of the other methods
+	wrapping := make(crypt.MethodDecrypter, len(m))
+	for method, d := range m {
+		wrapping[method] = d
+	}
+	m[crypt.MethodSIV] = crypt.NewSIVDecrypter(crypt.NewDataKey(config.SIVKey(), wrapping))
+	return m
+}
+
+// pgpEncrypter returns an Encrypter for the OpenPGP keys with the given
+// fingerprints in the pgp.keyring file
+func pgpEncrypter(fingerprints []string) (crypt.Encrypter, error) {
+	keyring := config.PGP().Keyring
+	if keyring == "" {
+		return nil, errors.New("pgp.keyring must be set to encrypt with OpenPGP")
+	}
+	if len(fingerprints) == 0 {
+		return nil, errors.New("no OpenPGP recipients given, use --pgp or pgp.recipients")
+	}
+	return crypt.LoadPGPEncrypter(keyring, fingerprints...)
+}
+
+// vaultTransit returns the Vault transit KMS from the vault settings
+func vaultTransit() (*crypt.VaultTransit, error) {
+	settings := config.Vault()
+	if settings.Address == "" {
+		return nil, errors.New("vault.address or VAULT_ADDR must be set")
+	}
+
+	v := crypt.NewVaultTransit(settings.Address, settings.Token, settings.Key)
+	if settings.Mount != "" {
+		v.Mount = settings.Mount
+	}
+	return v, nil
+}
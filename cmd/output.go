@@ -0,0 +1,168 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/config"
+	"github.com/mshindle/smithy/internal/safefile"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const backupSuffix = ".bak"
+
+// addOutputFlags adds the flags controlling where results are written,
+// binding them in viper under prefix
+func addOutputFlags(cmd *cobra.Command, prefix string) {
+	cmd.Flags().BoolP("in-place", "i", false, "overwrite the input file with the result")
+	cmd.Flags().StringP("output", "o", "", "write the result to a file instead of stdout")
+	cmd.Flags().Bool("backup", false, "keep a copy of any overwritten file with a "+backupSuffix+" suffix")
+	viper.BindPFlag(prefix+".inPlace", cmd.Flags().Lookup("in-place"))
+	viper.BindPFlag(prefix+".output", cmd.Flags().Lookup("output"))
+	viper.BindPFlag(prefix+".backup", cmd.Flags().Lookup("backup"))
+}
+
+// writeOutput sends the result of render to stdout, to the --output file,
+// or back over input when --in-place is set. When rel is not empty the
+// --output flag names a directory and the result is written to rel beneath
+// it. Files are replaced atomically and written with perm; with keep an
+// existing file keeps its mode instead. Plaintext must never be written
+// with keep, as it would take the mode of the encrypted file it replaces.
+func writeOutput(prefix string, input string, rel string, perm os.FileMode, keep bool, render func(io.Writer) error) error {
+	target, err := outputTarget(prefix, input, rel)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case target == "":
+		return render(os.Stdout)
+	case rel != "":
+		err = os.MkdirAll(filepath.Dir(target), 0700)
+		if err != nil {
+			return err
+		}
+	}
+
+	if viper.GetBool(prefix + ".backup") {
+		err = backupFile(target)
+		if err != nil {
+			return err
+		}
+	}
+
+	if keep {
+		perm = keepMode(target, perm)
+	}
+	return writeFileAtomic(target, perm, render)
+}
+
+// outputTarget returns the file writeOutput writes to, or "" for stdout
+func outputTarget(prefix string, input string, rel string) (string, error) {
+	inPlace := viper.GetBool(prefix + ".inPlace")
+	target := viper.GetString(prefix + ".output")
+
+	switch {
+	case inPlace && target != "":
+		return "", errors.New("--in-place and --output cannot be used together")
+	case inPlace:
+		if input == "" || input == "-" {
+			return "", errors.New("--in-place requires an input file")
+		}
+		return input, nil
+	case target == "" || target == "-":
+		return "", nil
+	case rel != "":
+		return filepath.Join(target, rel), nil
+	}
+	return target, nil
+}
+
+// fileID returns the identity of file for values bound to their file: the
+// --file-id flag, else the path of file relative to --bind-root or the
+// bindRoot setting. It is "" if neither is set or file is stdin or stdout.
+func fileID(prefix string, file string) (string, error) {
+	if id := viper.GetString(prefix + ".fileID"); id != "" {
+		return id, nil
+	}
+	root := viper.GetString(prefix + ".bindRoot")
+	if root == "" {
+		root = config.BindRoot()
+	}
+	if root == "" || file == "" || file == "-" {
+		return "", nil
+	}
+
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absRoot, absFile)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the bind root %s", file, root)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// keepMode returns the mode of file if it exists, otherwise perm
+func keepMode(file string, perm os.FileMode) os.FileMode {
+	if fi, err := os.Stat(file); err == nil {
+		return fi.Mode().Perm()
+	}
+	return perm
+}
+
+// writeFileAtomic renders into a temporary file next to file with mode
+// perm and renames it into place
+func writeFileAtomic(file string, perm os.FileMode, render func(io.Writer) error) error {
+	log.WithFields(log.Fields{"file": file, "mode": perm}).Debug("replacing file")
+	return safefile.WriteFile(file, perm, render)
+}
+
+// backupFile copies file to file.bak, keeping its mode. A missing file
+// has nothing to back up.
+func backupFile(file string) error {
+	in, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	log.WithField("file", file+backupSuffix).Info("writing backup")
+	return writeFileAtomic(file+backupSuffix, fi.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
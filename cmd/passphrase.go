@@ -0,0 +1,85 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mshindle/smithy/crypt"
+	"golang.org/x/term"
+)
+
+// readPassphrase returns the passphrase for the key described by name,
+// taken from SMITHY_KEY_PASSPHRASE or asked for on the terminal
+func readPassphrase(name string) ([]byte, error) {
+	if v, ok := os.LookupEnv(crypt.PassphraseEnv); ok {
+		return []byte(v), nil
+	}
+	return promptPassphrase(fmt.Sprintf("Enter passphrase for %s: ", name))
+}
+
+// newPassphrase asks twice for a new passphrase unless SMITHY_KEY_PASSPHRASE
+// is set. It is only called when protection was asked for, so an empty
+// passphrase is an error rather than a key silently left unprotected.
+func newPassphrase() ([]byte, error) {
+	var passphrase []byte
+	var err error
+	if v, ok := os.LookupEnv(crypt.PassphraseEnv); ok {
+		passphrase = []byte(v)
+	} else {
+		passphrase, err = confirmPassphrase()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase, the key would be stored unprotected")
+	}
+	return passphrase, nil
+}
+
+// confirmPassphrase asks for a new passphrase on the terminal twice
+func confirmPassphrase() ([]byte, error) {
+	first, err := promptPassphrase("Enter new passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	second, err := promptPassphrase("Enter same passphrase again: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(first, second) {
+		return nil, errors.New("passphrases do not match")
+	}
+	return first, nil
+}
+
+// promptPassphrase reads a passphrase from the controlling terminal
+// without echoing it
+func promptPassphrase(prompt string) ([]byte, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no terminal to ask for it and %s is not set", crypt.ErrPassphraseRequired, crypt.PassphraseEnv)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	b, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	return b, err
+}
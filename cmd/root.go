@@ -15,15 +15,17 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/mshindle/smithy/config"
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
-	"github.com/mshindle/smithy/data"
 )
 
 const defaultBaseDir = "$HOME/.smithy"
@@ -43,25 +45,64 @@ in a secure way. Instead of encrypting an entire file and losing the
 ability to modify non-sensitive data unless sensitive data is exposed, 
 smithy will detect if a field is encrypted and decrypt as appropriate.`,
 
+	// errors are reported once by Execute
+	SilenceErrors: true,
+
 	// ensure that the base dir exists
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		// set logging level
 		config.UpdateLogging()
 
 		// create our basedir if not existent
-		config.CreateBaseDir()
+		return config.CreateBaseDir()
 	},
 }
 
+// Exit codes returned by smithy
+const (
+	exitError             = 1
+	exitUnsupportedFormat = 3
+	exitNotEncrypted      = 4
+	exitMalformedEnvelope = 5
+	exitKeyNotFound       = 6
+	exitWrongKey          = 7
+	exitPassphrase        = 8
+	exitMAC               = 9
+	exitSignature         = 10
+)
+
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(-1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode(err))
 	}
 }
 
+// exitCode maps an error onto the exit code describing it
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrUnsupportedFormat):
+		return exitUnsupportedFormat
+	case errors.Is(err, crypt.ErrNotEncrypted):
+		return exitNotEncrypted
+	case errors.Is(err, crypt.ErrMalformedEnvelope):
+		return exitMalformedEnvelope
+	case errors.Is(err, crypt.ErrKeyNotFound), errors.Is(err, crypt.ErrInsecureKey):
+		return exitKeyNotFound
+	case errors.Is(err, crypt.ErrWrongKey):
+		return exitWrongKey
+	case errors.Is(err, crypt.ErrPassphraseRequired), errors.Is(err, crypt.ErrIncorrectPassphrase):
+		return exitPassphrase
+	case errors.Is(err, data.ErrNoMAC), errors.Is(err, data.ErrMACMismatch), errors.Is(err, data.ErrPublicKeyMAC):
+		return exitMAC
+	case errors.Is(err, data.ErrNoSignature), errors.Is(err, data.ErrUntrustedSigner), errors.Is(err, crypt.ErrBadSignature):
+		return exitSignature
+	}
+	return exitError
+}
+
 func init() {
 	// initialize logging
 	log.SetOutput(os.Stderr)
@@ -90,6 +131,7 @@ func initConfig() {
 	viper.AddConfigPath(defaultBaseDir)   // adding home directory as first search path
 	viper.AddConfigPath(defaultSystemDir) // adding system directory as second search path
 	viper.AutomaticEnv()                  // read in environment variables that match
+	viper.BindEnv("allowInsecureKeys", "SMITHY_ALLOW_INSECURE_KEYS")
 
 	// If a config file is found, read it in.
 	if err := viper.ReadInConfig(); err == nil {
@@ -104,8 +146,16 @@ func initConfig() {
 	viper.SetDefault("encryptMethod", "rsa")
 	viper.SetDefault("publicKey", "public.key")
 	viper.SetDefault("privateKey", "private.key")
+	viper.SetDefault("sivKey", "siv.key")
 	viper.SetDefault("logging.level", "warn")
 
 	// load into settings
 	config.Initialize()
+	crypt.AllowInsecureKeys = config.AllowInsecureKeys()
+
+	// make any external format processors available
+	for _, p := range config.Plugins() {
+		log.WithFields(log.Fields{"format": p.Name, "command": p.Command}).Debug("registering format plugin")
+		data.RegisterPlugin(p.Name, p.Extensions, p.Command, p.Args...)
+	}
 }
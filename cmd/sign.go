@@ -0,0 +1,170 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/config"
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// signCmd signs files
+var signCmd = &cobra.Command{
+	Use:   "sign file...",
+	Short: "sign files to show who produced them",
+	Long: `
+sign signs the canonical form of each file, every value except the smithy
+metadata block, with an Ed25519, RSA (PSS) or ECDSA private key. The
+signature is stored inline in the smithy metadata block, or with
+--sidecar in a file named after the input with a .sig suffix.
+
+The signing key is read from --key, the SMITHY_PRIVATE_KEY environment
+variable or the privateKey setting, in that order.`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runSign,
+	SilenceUsage: true,
+}
+
+// verifyCmd checks the signatures of files
+var verifyCmd = &cobra.Command{
+	Use:   "verify file...",
+	Short: "check that files are signed by a trusted signer",
+	Long: `
+verify checks the inline or sidecar signature of each file against the
+keys listed in the trustedSigners setting and prints who signed it.`,
+	Args:         cobra.MinimumNArgs(1),
+	RunE:         runVerify,
+	SilenceUsage: true,
+}
+
+func init() {
+	RootCmd.AddCommand(signCmd)
+	RootCmd.AddCommand(verifyCmd)
+	signCmd.Flags().StringP("key", "k", "", "signing key source: a file, env:NAME, stdin, fd:N or cmd:COMMAND")
+	signCmd.Flags().Bool("sidecar", false, "write the signature to a .sig file instead of inline")
+	viper.BindPFlag("sign.key", signCmd.Flags().Lookup("key"))
+	viper.BindPFlag("sign.sidecar", signCmd.Flags().Lookup("sidecar"))
+}
+
+func runSign(cmd *cobra.Command, args []string) error {
+	provider, err := privateKeyProvider("sign.key")
+	if err != nil {
+		return err
+	}
+	key, err := crypt.NewDecrypter(provider, crypt.WithPassphrase(readPassphrase)).PrivateKey()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range args {
+		p, object, err := readObject(file)
+		if err != nil {
+			return err
+		}
+		sig, err := object.Sign(key)
+		if err != nil {
+			return data.InFile(err, file)
+		}
+
+		if viper.GetBool("sign.sidecar") {
+			err = writeFileAtomic(file+data.SignatureSuffix, keepMode(file+data.SignatureSuffix, 0644), func(w io.Writer) error {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "    ")
+				return enc.Encode(sig)
+			})
+		} else {
+			if _, ok := p.(*data.DotenvProcessor); ok {
+				return errors.New("dotenv files cannot hold the smithy metadata block, use --sidecar")
+			}
+			err = object.SetSignature(sig)
+			if err != nil {
+				return data.InFile(err, file)
+			}
+			err = writeFileAtomic(file, keepMode(file, 0644), func(w io.Writer) error {
+				return p.Encode(w, object)
+			})
+		}
+		if err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{"file": file, "signer": sig.Signer}).Info("file signed")
+	}
+	return nil
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	signers, err := trustedSigners()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range args {
+		_, object, err := readObject(file)
+		if err != nil {
+			return err
+		}
+		signer, err := object.VerifyFile(file, signers)
+		if err != nil {
+			return data.InFile(err, file)
+		}
+		fmt.Printf("%s: signed by %s\n", file, signer.Name)
+	}
+	return nil
+}
+
+// readObject reads and decodes file, picking the processor from its
+// extension or content
+func readObject(file string) (data.Processor, data.Object, error) {
+	b, err := readInput(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := selectProcessor("", file, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	object, err := p.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, data.InFile(err, file)
+	}
+	return p, object, nil
+}
+
+// trustedSigners loads the public keys of the trustedSigners setting
+func trustedSigners() ([]data.Signer, error) {
+	settings := config.TrustedSigners()
+	if len(settings) == 0 {
+		return nil, errors.New("no trustedSigners configured")
+	}
+
+	signers := make([]data.Signer, len(settings))
+	for i, s := range settings {
+		key, err := crypt.LoadPublicKey(s.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("trusted signer %s: %w", s.Name, err)
+		}
+		signers[i] = data.Signer{Name: s.Name, PublicKey: key}
+	}
+	return signers, nil
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	log "github.com/Sirupsen/logrus"
+	"github.com/mshindle/smithy/crypt"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
 )
@@ -17,13 +18,49 @@ type LogSettings struct {
 	Level string `yaml:"level"`
 }
 
+// PluginSettings describes an external processor for a data format
+type PluginSettings struct {
+	Name       string   `yaml:"name"`
+	Extensions []string `yaml:"extensions"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+}
+
+// VaultSettings holds the settings for the Vault transit KMS
+type VaultSettings struct {
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
+	Mount   string `yaml:"mount"`
+	Key     string `yaml:"key"`
+}
+
+// PGPSettings holds the settings for OpenPGP encryption
+type PGPSettings struct {
+	Keyring       string   `yaml:"keyring"`
+	SecretKeyring string   `yaml:"secretKeyring"`
+	Recipients    []string `yaml:"recipients"`
+}
+
+// SignerSettings names a public key trusted to sign documents
+type SignerSettings struct {
+	Name      string `yaml:"name"`
+	PublicKey string `yaml:"publicKey"`
+}
+
 // Settings holds the global settings
 type Settings struct {
-	BaseDir       string      `yaml:"baseDir"`
-	EncryptMethod string      `yaml:"encryptMethod"`
-	PublicKey     string      `yaml:"publicKey"`
-	PrivateKey    string      `yaml:"privateKey"`
-	Logging       LogSettings `yaml:"logging"`
+	BaseDir           string           `yaml:"baseDir"`
+	EncryptMethod     string           `yaml:"encryptMethod"`
+	PublicKey         string           `yaml:"publicKey"`
+	PrivateKey        string           `yaml:"privateKey"`
+	SIVKey            string           `yaml:"sivKey"`
+	BindRoot          string           `yaml:"bindRoot"`
+	AllowInsecureKeys bool             `yaml:"allowInsecureKeys"`
+	Logging           LogSettings      `yaml:"logging"`
+	Plugins           []PluginSettings `yaml:"plugins"`
+	Vault             VaultSettings    `yaml:"vault"`
+	PGP               PGPSettings      `yaml:"pgp"`
+	TrustedSigners    []SignerSettings `yaml:"trustedSigners"`
 }
 
 var config Settings
@@ -37,11 +74,16 @@ func Initialize() {
 	}
 }
 
-// CreateBaseDir creates the base directory in the configuration
+// CreateBaseDir creates the base directory in the configuration so that
+// only its owner can access it. An existing directory is never changed, as
+// it may be shared with other files such as the directory of the config
+// file, but one that others can access is refused as it holds the keys and
+// the agent socket, unless allowInsecureKeys is set.
 func CreateBaseDir() error {
 	path := expandPath(config.BaseDir)
 	logger := log.WithFields(log.Fields{"base_dir": config.BaseDir, "path": path})
-	if _, err := os.Stat(path); err != nil {
+	fi, err := os.Stat(path)
+	if err != nil {
 
 		logger.Info("base_dir does not exist. creating.")
 		err = os.MkdirAll(path, 0700)
@@ -49,22 +91,108 @@ func CreateBaseDir() error {
 			logger.Error("cannot create base_dir.")
 			return err
 		}
-	} else {
-		logger.Debug("base_dir exists. skipping.")
+		return nil
+	}
+
+	if !fi.IsDir() {
+		logger.Error("base_dir is not a directory.")
+		return fmt.Errorf("base dir %s is not a directory", path)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm()&0077 != 0 {
+		if !config.AllowInsecureKeys {
+			return fmt.Errorf("base dir %s is accessible by others (mode %04o), restrict it with chmod 700 or set allowInsecureKeys", path, fi.Mode().Perm())
+		}
+		logger.WithField("mode", fi.Mode().Perm()).Warn("base_dir is accessible by others. restrict it with chmod 700.")
 	}
+	logger.Debug("base_dir exists. skipping.")
 	return nil
 }
 
+// BaseDir returns the absolute path to the base directory
+func BaseDir() string {
+	return expandPath(config.BaseDir)
+}
+
 // PublicKey returns the absolute path to the public key
 func PublicKey() string {
 	return absPathToKey(config.PublicKey)
 }
 
-// PrivateKey returns the absolute path to the private key
+// PrivateKey returns the absolute path to the private key, or the key
+// source unchanged if it is not a file (such as env:NAME or cmd:COMMAND)
 func PrivateKey() string {
+	if crypt.HasKeyScheme(config.PrivateKey) {
+		return config.PrivateKey
+	}
 	return absPathToKey(config.PrivateKey)
 }
 
+// BindRoot returns the directory file identities of bound values are
+// relative to, or "" if it is not set
+func BindRoot() string {
+	if config.BindRoot == "" {
+		return ""
+	}
+	return expandPath(config.BindRoot)
+}
+
+// SIVKey returns the absolute path to the wrapped data key used by the
+// siv encryptMethod
+func SIVKey() string {
+	return absPathToKey(config.SIVKey)
+}
+
+// AllowInsecureKeys reports whether private key files readable by other
+// users may be loaded
+func AllowInsecureKeys() bool {
+	return config.AllowInsecureKeys
+}
+
+// EncryptMethod returns the method used to encrypt new values
+func EncryptMethod() string {
+	return config.EncryptMethod
+}
+
+// Vault returns the Vault settings. The address and token fall back to
+// the VAULT_ADDR and VAULT_TOKEN environment variables used by Vault itself.
+func Vault() VaultSettings {
+	v := config.Vault
+	if v.Address == "" {
+		v.Address = os.Getenv("VAULT_ADDR")
+	}
+	if v.Token == "" {
+		v.Token = os.Getenv("VAULT_TOKEN")
+	}
+	return v
+}
+
+// PGP returns the OpenPGP settings with the keyring paths made absolute
+func PGP() PGPSettings {
+	p := config.PGP
+	if p.Keyring != "" {
+		p.Keyring = absPathToKey(p.Keyring)
+	}
+	if p.SecretKeyring != "" {
+		p.SecretKeyring = absPathToKey(p.SecretKeyring)
+	}
+	return p
+}
+
+// TrustedSigners returns the keys trusted to sign documents with their
+// paths made absolute
+func TrustedSigners() []SignerSettings {
+	signers := make([]SignerSettings, len(config.TrustedSigners))
+	for i, s := range config.TrustedSigners {
+		signers[i] = SignerSettings{Name: s.Name, PublicKey: absPathToKey(s.PublicKey)}
+	}
+	return signers
+}
+
+// Plugins returns the external format processors from the configuration
+func Plugins() []PluginSettings {
+	return config.Plugins
+}
+
 func absPathToKey(key string) string {
 	if filepath.IsAbs(key) {
 		return key
@@ -113,7 +241,11 @@ func UserHomeDir() string {
 // Print dumps the current configuration to stdout
 func Print() {
 	log.Info("marshaling output as yaml")
-	out, err := yaml.Marshal(&config)
+	printed := config
+	if printed.Vault.Token != "" {
+		printed.Vault.Token = "****"
+	}
+	out, err := yaml.Marshal(&printed)
 	if err != nil {
 		log.WithError(err).Fatal("could not marshal configuration")
 	}
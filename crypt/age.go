@@ -0,0 +1,106 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"filippo.io/age"
+)
+
+// MethodAge is the method name of values holding an age
+// (age-encryption.org) file. The payload decodes to a file which the age
+// tool decrypts as is.
+const MethodAge = "age"
+
+// AgeIdentities holds the identities read from an age identity file. It
+// is the private key loaded by a KeyDecrypter for such files.
+type AgeIdentities []age.Identity
+
+// isAgeIdentity reports whether b looks like an age identity file
+func isAgeIdentity(b []byte) bool {
+	return bytes.Contains(b, []byte("AGE-SECRET-KEY-1"))
+}
+
+// AgeEncrypter encrypts values to age recipients. The age format has no
+// associated data, so the label is not bound to age values.
+type AgeEncrypter struct {
+	recipients []age.Recipient
+}
+
+// NewAgeEncrypter returns an Encrypter for the given age recipients
+func NewAgeEncrypter(recipients ...age.Recipient) *AgeEncrypter {
+	return &AgeEncrypter{recipients: recipients}
+}
+
+// LoadAgeEncrypter returns an Encrypter for the recipients listed in
+// file, one age1 public key per line as accepted by age -R
+func LoadAgeEncrypter(file string) (*AgeEncrypter, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, err)
+	}
+	recipients, err := age.ParseRecipients(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return NewAgeEncrypter(recipients...), nil
+}
+
+// Encrypt encrypts data into an age file
+func (e *AgeEncrypter) Encrypt(data []byte, label string) (*Envelope, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, e.recipients...)
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{Method: MethodAge, Payload: buf.Bytes()}, nil
+}
+
+// parseAgeIdentities parses an age identity file
+func parseAgeIdentities(b []byte) (AgeIdentities, error) {
+	ids, err := age.ParseIdentities(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return AgeIdentities(ids), nil
+}
+
+// decryptAge opens the age file held in an envelope
+func decryptAge(ids AgeIdentities, env *Envelope) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(env.Payload), ids...)
+	var noMatch *age.NoIdentityMatchError
+	if errors.As(err, &noMatch) {
+		return nil, ErrWrongKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWrongKey, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,115 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ParamBind is the envelope parameter naming what a value is bound to
+const ParamBind = "bind"
+
+// Values of the bind parameter
+const (
+	BindPath     = "path"
+	BindPathFile = "path+file"
+)
+
+// Binding identifies where a value is stored. A bound value is encrypted
+// with its location appended to the label as associated data, so it no
+// longer decrypts when copied to another field or file.
+type Binding struct {
+	// Path is the dotted path of the field holding the value
+	Path string
+	// File identifies the file holding the value. It is only used for
+	// values bound with BindPathFile.
+	File string
+}
+
+// label returns the label authenticated with a value bound to b
+func (b Binding) label(label string, mode string) string {
+	label += "\x00" + b.Path
+	if mode == BindPathFile {
+		label += "\x00" + b.File
+	}
+	return label
+}
+
+// EncryptBound encrypts data with e and binds the value to b.Path, and
+// to b.File as well if it is not empty
+func EncryptBound(e Encrypter, data []byte, label string, b Binding) (string, error) {
+	if b.Path == "" {
+		return "", errors.New("cannot bind a value to an empty path")
+	}
+	mode := BindPath
+	if b.File != "" {
+		mode = BindPathFile
+	}
+
+	env, err := e.Encrypt(data, b.label(label, mode))
+	if err != nil {
+		return "", err
+	}
+	if env.Method == MethodAge {
+		return "", errors.New("age values have no associated data and cannot be bound")
+	}
+	if env.Params == nil {
+		env.Params = make(map[string]string)
+	}
+	env.Params[ParamBind] = mode
+	return env.String(), nil
+}
+
+// IsBound reports whether the ENC[...] string s is bound to where it is
+// stored, so it can only be decrypted by DecryptBound
+func IsBound(s string) bool {
+	env, err := ParseEnvelope(s, AllowWhitespace())
+	return err == nil && env.Param(ParamBind) != ""
+}
+
+// DecryptBound parses the ENC[...] string s stored at b and decrypts it
+// with d. Bound values only decrypt at the path, and file, they were
+// encrypted for; values without a binding are decrypted as by DecryptWith
+// unless RequireBinding is given.
+func DecryptBound(d Decrypter, s string, label string, b Binding, opts ...ParseOption) ([]byte, error) {
+	env, err := ParseEnvelope(s, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := env.Param(ParamBind)
+	switch mode {
+	case "":
+		return d.Decrypt(env, label)
+	case BindPath:
+	case BindPathFile:
+		if b.File == "" {
+			return nil, fmt.Errorf("%w: value is bound to its file but the file is unknown", ErrWrongKey)
+		}
+	default:
+		return nil, malformed("unknown binding %q", mode)
+	}
+
+	plain, err := d.Decrypt(env, b.label(label, mode))
+	if errors.Is(err, ErrWrongKey) {
+		bound := "field path"
+		if mode == BindPathFile {
+			bound = "field path and file"
+		}
+		return nil, fmt.Errorf("%w, or it was moved from the %s it is bound to", err, bound)
+	}
+	return plain, err
+}
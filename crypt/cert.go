@@ -0,0 +1,136 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// pemCertificate is the PEM type of X.509 certificates
+const pemCertificate = "CERTIFICATE"
+
+// CertEncrypter encrypts values to the public key of an X.509
+// certificate, recording the certificate fingerprint in the cert
+// parameter of each envelope
+type CertEncrypter struct {
+	Certificate *x509.Certificate
+	enc         Encrypter
+}
+
+// NewCertEncrypter returns an Encrypter for the RSA or ECDSA key of cert
+func NewCertEncrypter(cert *x509.Certificate) (*CertEncrypter, error) {
+	var enc Encrypter
+	var err error
+	switch k := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		enc = NewRSAEncrypter(k)
+	case *ecdsa.PublicKey:
+		ecdhKey, kerr := k.ECDH()
+		if kerr != nil {
+			return nil, kerr
+		}
+		enc, err = NewECIESEncrypter(ecdhKey)
+	default:
+		err = fmt.Errorf("unsupported certificate key type %T", cert.PublicKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &CertEncrypter{Certificate: cert, enc: enc}, nil
+}
+
+// LoadCertEncrypter returns an Encrypter for the PEM certificate stored in file
+func LoadCertEncrypter(file string) (*CertEncrypter, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, err)
+	}
+	cert, err := ParseCertificate(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return NewCertEncrypter(cert)
+}
+
+// ParseCertificate parses a PEM encoded X.509 certificate
+func ParseCertificate(b []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != pemCertificate {
+		return nil, fmt.Errorf("data is not a PEM encoded certificate")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// isCertificate reports whether b holds a PEM encoded certificate
+func isCertificate(b []byte) bool {
+	block, _ := pem.Decode(b)
+	return block != nil && block.Type == pemCertificate
+}
+
+// Fingerprint returns the hex encoded SHA-256 fingerprint of cert
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Encrypt encrypts data to the certificate key
+func (e *CertEncrypter) Encrypt(data []byte, label string) (*Envelope, error) {
+	env, err := e.enc.Encrypt(data, label)
+	if err != nil {
+		return nil, err
+	}
+	if env.Params == nil {
+		env.Params = make(map[string]string)
+	}
+	env.Params["cert"] = Fingerprint(e.Certificate)
+	return env, nil
+}
+
+// Warnings describes why the certificate should not be used for
+// encryption at time now: it is not yet or no longer valid, or its key
+// usage does not allow encryption. A certificate without a key usage
+// extension may be used for anything.
+func (e *CertEncrypter) Warnings(now time.Time) []string {
+	var warnings []string
+	cert := e.Certificate
+	if now.After(cert.NotAfter) {
+		warnings = append(warnings, fmt.Sprintf("certificate expired on %s", cert.NotAfter.Format(time.RFC3339)))
+	}
+	if now.Before(cert.NotBefore) {
+		warnings = append(warnings, fmt.Sprintf("certificate is not valid before %s", cert.NotBefore.Format(time.RFC3339)))
+	}
+
+	if cert.KeyUsage != 0 {
+		switch cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if cert.KeyUsage&x509.KeyUsageKeyEncipherment == 0 {
+				warnings = append(warnings, "certificate key usage does not include keyEncipherment")
+			}
+		case *ecdsa.PublicKey:
+			if cert.KeyUsage&x509.KeyUsageKeyAgreement == 0 {
+				warnings = append(warnings, "certificate key usage does not include keyAgreement")
+			}
+		}
+	}
+	return warnings
+}
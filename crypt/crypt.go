@@ -15,46 +15,63 @@
 package crypt
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
-
-	log "github.com/Sirupsen/logrus"
+	"fmt"
 )
 
 const BitSize = 1024
 
-// Generate creates a public / private key pair and saves them in the specified file
-func Generate(pubfile string, privfile string) error {
-	key, err := rsa.GenerateKey(rand.Reader, BitSize)
-	if err != nil {
-		return err
-	}
-	pubkey := key.PublicKey
+// Key algorithms accepted by Generate
+const (
+	AlgorithmRSA     = "rsa"
+	AlgorithmP256    = "p256"
+	AlgorithmP384    = "p384"
+	AlgorithmEd25519 = "ed25519"
+)
 
-	log.WithFields(log.Fields{
-		"prime0":         key.Primes[0].String(),
-		"prime1":         key.Primes[1].String(),
-		"exponent":       key.D.String(),
-		"pubkeyModulus":  pubkey.N.String(),
-		"pubkeyExponent": pubkey.E,
-	}).Info("key generated")
+// Algorithms lists the key algorithms accepted by Generate
+var Algorithms = []string{AlgorithmRSA, AlgorithmP256, AlgorithmP384, AlgorithmEd25519}
 
-	err = saveKey(privfile, key)
+// GenerateKey creates a private key for algorithm. RSA keys are used with
+// RSA-OAEP, P-256 and P-384 keys with ECIES, and Ed25519 keys with ECIES
+// on X25519. All of them can sign documents.
+func GenerateKey(algorithm string) (crypto.Signer, error) {
+	switch algorithm {
+	case AlgorithmRSA, "":
+		return rsa.GenerateKey(rand.Reader, BitSize)
+	case AlgorithmP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgorithmP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case AlgorithmEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, fmt.Errorf("unsupported key algorithm %q", algorithm)
+}
+
+// Generate creates a public / private key pair for algorithm and saves them in the specified file.
+// The private key is encrypted with passphrase unless it is empty.
+func Generate(pubfile string, privfile string, algorithm string, passphrase []byte) error {
+	key, err := GenerateKey(algorithm)
 	if err != nil {
-		log.WithField("file", privfile).Error("could not save private key")
 		return err
 	}
 
-	err = saveKey(pubfile, pubkey)
+	err = WritePrivateKey(privfile, key, passphrase)
 	if err != nil {
-		log.WithField("file", pubfile).Error("could not save public key")
-		return err
+		return fmt.Errorf("could not save private key %s: %v", privfile, err)
 	}
 
-	log.WithFields(log.Fields{
-		"privfile": privfile,
-		"pubfile":  pubfile,
-	}).Info("all key files written")
+	err = savePublicKey(pubfile, key.Public())
+	if err != nil {
+		return fmt.Errorf("could not save public key %s: %v", pubfile, err)
+	}
 
 	return nil
 }
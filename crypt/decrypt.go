@@ -14,43 +14,24 @@
 
 package crypt
 
-import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"encoding/base64"
-
-	log "github.com/Sirupsen/logrus"
-)
+// DecryptWith parses the ENC[...] string s and decrypts it with d
+func DecryptWith(d Decrypter, s string, label string, opts ...ParseOption) ([]byte, error) {
+	env, err := ParseEnvelope(s, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return d.Decrypt(env, label)
+}
 
 // DecryptFromString decrypts a standard base64
 // encoded string, as defined in RFC 4648, wrapped in an
 // "ENC[" and "]" construct
-func DecryptFromString(s string, label string, file string) ([]byte, error) {
-	b64string := s[4 : len(s)-1]
-	log.WithField("base64", b64string).Debug("string to decode")
-	decodeBytes, err := base64.StdEncoding.DecodeString(b64string)
-	if err != nil {
-		return nil, err
-	}
-	return Decrypt(decodeBytes, label, file)
+func DecryptFromString(s string, label string, file string, opts ...ParseOption) ([]byte, error) {
+	return DecryptWith(NewDecrypter(FileKey(file)), s, label, opts...)
 }
 
 // Decrypt will decrypt the data bytes using the PEM
 // private key
 func Decrypt(data []byte, label string, file string) ([]byte, error) {
-	var privkey rsa.PrivateKey
-	err := loadKey(file, &privkey)
-	if err != nil {
-		log.WithField("file", file).WithError(err).Error("could not load private key")
-		return nil, err
-	}
-
-	decryptedValue, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, &privkey, data, []byte(label))
-	if err != nil {
-		log.WithError(err).WithField("value", decryptedValue).Error("could not decrypt data")
-		return nil, err
-	}
-
-	return decryptedValue, nil
+	return NewDecrypter(FileKey(file)).Decrypt(&Envelope{Method: MethodRSA, Payload: data}, label)
 }
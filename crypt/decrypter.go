@@ -0,0 +1,195 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Decrypter decrypts the payload of an envelope. The label must match
+// the one the value was encrypted with.
+type Decrypter interface {
+	Decrypt(env *Envelope, label string) ([]byte, error)
+}
+
+// MethodDecrypter dispatches each envelope to the Decrypter registered
+// for its method
+type MethodDecrypter map[string]Decrypter
+
+// Decrypt decrypts env with the Decrypter for its method
+func (m MethodDecrypter) Decrypt(env *Envelope, label string) ([]byte, error) {
+	d, ok := m[env.Method]
+	if !ok {
+		return nil, fmt.Errorf("%w: no key configured for method %q", ErrKeyNotFound, env.Method)
+	}
+	return d.Decrypt(env, label)
+}
+
+// KeyDecrypter decrypts envelopes with a private key from a KeyProvider.
+// The key is loaded on first use and then kept in memory, so a provider
+// such as stdin is only read once.
+type KeyDecrypter struct {
+	provider   KeyProvider
+	passphrase PassphraseFunc
+	once       sync.Once
+	key        crypto.PrivateKey
+	err        error
+}
+
+// DecrypterOption configures a KeyDecrypter
+type DecrypterOption func(*KeyDecrypter)
+
+// WithPassphrase sets how the passphrase of a protected key is obtained
+func WithPassphrase(fn PassphraseFunc) DecrypterOption {
+	return func(d *KeyDecrypter) {
+		d.passphrase = fn
+	}
+}
+
+// NewDecrypter returns a Decrypter using the key supplied by p. Protected
+// keys are unlocked with the passphrase from SMITHY_KEY_PASSPHRASE unless
+// WithPassphrase is given.
+func NewDecrypter(p KeyProvider, opts ...DecrypterOption) *KeyDecrypter {
+	d := &KeyDecrypter{provider: p, passphrase: EnvPassphrase(PassphraseEnv)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// PrivateKey loads and parses the private key, unlocking it if needed
+func (d *KeyDecrypter) PrivateKey() (crypto.PrivateKey, error) {
+	d.once.Do(func() {
+		d.key, d.err = d.load()
+	})
+	return d.key, d.err
+}
+
+func (d *KeyDecrypter) load() (crypto.PrivateKey, error) {
+	b, err := d.provider.KeyBytes()
+	if errors.Is(err, ErrInsecureKey) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrKeyNotFound, d.provider, err)
+	}
+
+	if isAgeIdentity(b) {
+		ids, err := parseAgeIdentities(b)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrKeyNotFound, d.provider, err)
+		}
+		return ids, nil
+	}
+
+	if IsKeyShare(b) {
+		key, err := combineKeyShareBytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrKeyNotFound, d.provider, err)
+		}
+		return key, nil
+	}
+
+	if isPGPKeyRing(b) {
+		keyring, err := parsePGPKeyRing(b, d.provider.String(), d.passphrase)
+		if errors.Is(err, ErrPassphraseRequired) || errors.Is(err, ErrIncorrectPassphrase) {
+			return nil, fmt.Errorf("%s: %w", d.provider, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrKeyNotFound, d.provider, err)
+		}
+		return keyring, nil
+	}
+
+	key, err := ParsePrivateKey(b)
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, ErrPassphraseRequired) {
+		return nil, fmt.Errorf("%w: %s: %v", ErrKeyNotFound, d.provider, err)
+	}
+
+	passphrase, err := d.passphrase(d.provider.String())
+	if err != nil {
+		return nil, err
+	}
+	key, err = ParsePrivateKeyWithPassphrase(b, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.provider, err)
+	}
+	return key, nil
+}
+
+// Decrypt decrypts the envelope payload with the private key
+func (d *KeyDecrypter) Decrypt(env *Envelope, label string) ([]byte, error) {
+	key, err := d.PrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	switch env.Method {
+	case MethodRSA:
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, ErrWrongKey
+		}
+		b, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, rsaKey, env.Payload, []byte(label))
+		if err != nil {
+			return nil, ErrWrongKey
+		}
+		return b, nil
+	case MethodECIES:
+		ecdhKey, err := ecdhPrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return decryptECIES(ecdhKey, env, label)
+	case MethodAge:
+		ids, ok := key.(AgeIdentities)
+		if !ok {
+			return nil, ErrWrongKey
+		}
+		return decryptAge(ids, env)
+	case MethodPGP:
+		keyring, ok := key.(PGPKeyRing)
+		if !ok {
+			return nil, ErrWrongKey
+		}
+		return decryptPGP(keyring, env, label)
+	}
+	return nil, fmt.Errorf("%w: unknown method %q", ErrMalformedEnvelope, env.Method)
+}
+
+// ecdhPrivateKey returns the key agreement key for a private key
+func ecdhPrivateKey(key crypto.PrivateKey) (*ecdh.PrivateKey, error) {
+	switch k := key.(type) {
+	case *ecdh.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k.ECDH()
+	case ed25519.PrivateKey:
+		return ed25519PrivateKeyToECDH(k)
+	}
+	return nil, ErrWrongKey
+}
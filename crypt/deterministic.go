@@ -0,0 +1,207 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/mshindle/smithy/internal/safefile"
+	"golang.org/x/crypto/hkdf"
+)
+
+// MethodSIV identifies values encrypted deterministically with AES-SIV.
+//
+// Encrypting the same plaintext with the same data key and label always
+// gives the same ciphertext, so re-encrypting an unchanged file leaves it
+// unchanged. The price is that anyone can tell when two values are equal,
+// or when a value changes back to an earlier one, without decrypting
+// them. Bind values to their field path so only copies of the same field
+// compare equal, and use another method for low entropy secrets.
+const MethodSIV = "siv"
+
+// sivKeySize is the size of the AES-256-SIV keys derived from data keys.
+// The data key itself is smaller so it can be wrapped by any method,
+// including RSA-OAEP with short keys.
+const sivKeySize = 64
+
+// dataKeyLabel is the label the data key is wrapped with
+const dataKeyLabel = "smithy siv data key"
+
+// DataKey is the seed of an AES-SIV key stored in a file, wrapped for one or more
+// recipients with one ENC[...] value per line. The file is read and the
+// key unwrapped on first use, and then kept in memory.
+type DataKey struct {
+	file string
+	d    Decrypter
+	once sync.Once
+	key  []byte
+	err  error
+}
+
+// NewDataKey returns the data key in file, unwrapped with d
+func NewDataKey(file string, d Decrypter) *DataKey {
+	return &DataKey{file: file, d: d}
+}
+
+// GenerateDataKey creates a data key and saves it in file, wrapped for
+// each of the recipients
+func GenerateDataKey(file string, recipients ...Encrypter) error {
+	if len(recipients) == 0 {
+		return errors.New("no recipients to wrap the data key for")
+	}
+
+	key := make([]byte, dataKeySize)
+	_, err := randRead(key)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, e := range recipients {
+		s, err := EncryptWith(e, key, dataKeyLabel)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s + "\n")
+	}
+	return safefile.WriteFile(file, 0600, safefile.Bytes(buf.Bytes()))
+}
+
+// Key returns the AES-SIV key derived from the unwrapped data key
+func (k *DataKey) Key() ([]byte, error) {
+	k.once.Do(func() {
+		k.key, k.err = k.load()
+	})
+	return k.key, k.err
+}
+
+// ID identifies the data key in envelopes
+func (k *DataKey) ID() (string, error) {
+	key, err := k.Key()
+	if err != nil {
+		return "", err
+	}
+	return dataKeyID(key), nil
+}
+
+// load unwraps the first value in the file the Decrypter has a key for
+func (k *DataKey) load() ([]byte, error) {
+	b, err := ioutil.ReadFile(k.file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read data key: %w", err)
+	}
+
+	err = fmt.Errorf("%w: no values in data key file %s", ErrMalformedEnvelope, k.file)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var seed []byte
+		seed, err = DecryptWith(k.d, line, dataKeyLabel)
+		if err == nil {
+			if len(seed) != dataKeySize {
+				return nil, fmt.Errorf("%w: data key has %d bytes", ErrMalformedEnvelope, len(seed))
+			}
+			key := make([]byte, sivKeySize)
+			_, err = io.ReadFull(hkdf.New(sha256.New, seed, nil, []byte("smithy siv")), key)
+			return key, err
+		}
+	}
+	return nil, err
+}
+
+// dataKeyID returns a short fingerprint of the data key
+func dataKeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("smithy siv key id\x00"), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// SIVEncrypter encrypts values deterministically with AES-SIV under a
+// data key. The label is authenticated as associated data.
+type SIVEncrypter struct {
+	key *DataKey
+}
+
+// NewSIVEncrypter returns an Encrypter using the data key
+func NewSIVEncrypter(key *DataKey) *SIVEncrypter {
+	return &SIVEncrypter{key: key}
+}
+
+// Encrypt seals data. The payload is the synthetic IV followed by the
+// ciphertext and the key parameter identifies the data key.
+func (e *SIVEncrypter) Encrypt(data []byte, label string) (*Envelope, error) {
+	key, err := e.key.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := sivSeal(key, data, []byte(label))
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Method:  MethodSIV,
+		Params:  map[string]string{"key": dataKeyID(key)},
+		Payload: sealed,
+	}, nil
+}
+
+// Decrypter returns a Decrypter for the values e encrypts, sharing its
+// unwrapped data key
+func (e *SIVEncrypter) Decrypter() Decrypter {
+	return NewSIVDecrypter(e.key)
+}
+
+// SIVDecrypter decrypts values encrypted by SIVEncrypter
+type SIVDecrypter struct {
+	key *DataKey
+}
+
+// NewSIVDecrypter returns a Decrypter using the data key
+func NewSIVDecrypter(key *DataKey) *SIVDecrypter {
+	return &SIVDecrypter{key: key}
+}
+
+// Decrypt opens env after checking it was sealed with the data key
+func (d *SIVDecrypter) Decrypt(env *Envelope, label string) ([]byte, error) {
+	if env.Method != MethodSIV {
+		return nil, fmt.Errorf("%w: unknown method %q", ErrMalformedEnvelope, env.Method)
+	}
+
+	key, err := d.key.Key()
+	if err != nil {
+		return nil, err
+	}
+	if id := env.Param("key"); id != dataKeyID(key) {
+		return nil, fmt.Errorf("%w: value was encrypted with data key %q", ErrKeyNotFound, id)
+	}
+
+	data, err := sivOpen(key, env.Payload, []byte(label))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWrongKey, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,143 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// MethodECIES is the method name of values encrypted to an elliptic curve
+// key. The curve is recorded in the curve parameter of the envelope.
+const MethodECIES = "ecies"
+
+// Curves accepted in the curve parameter of ECIES envelopes
+const (
+	CurveX25519 = "x25519"
+	CurveP256   = "p256"
+	CurveP384   = "p384"
+)
+
+// eciesCurves maps curve names onto their implementation
+var eciesCurves = map[string]ecdh.Curve{
+	CurveX25519: ecdh.X25519(),
+	CurveP256:   ecdh.P256(),
+	CurveP384:   ecdh.P384(),
+}
+
+// curveName returns the envelope name of curve
+func curveName(curve ecdh.Curve) (string, error) {
+	for name, c := range eciesCurves {
+		if c == curve {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported curve %v", curve)
+}
+
+// ECIESEncrypter encrypts values to an elliptic curve public key. Each
+// value uses a fresh ephemeral key; the shared secret is run through
+// HKDF-SHA256 to key AES-256-GCM, and the payload is the ephemeral public
+// key followed by the sealed value.
+type ECIESEncrypter struct {
+	curve string
+	key   *ecdh.PublicKey
+}
+
+// NewECIESEncrypter returns an Encrypter for an ECDH public key
+func NewECIESEncrypter(key *ecdh.PublicKey) (*ECIESEncrypter, error) {
+	name, err := curveName(key.Curve())
+	if err != nil {
+		return nil, err
+	}
+	return &ECIESEncrypter{curve: name, key: key}, nil
+}
+
+// Encrypt encrypts data to the public key
+func (e *ECIESEncrypter) Encrypt(data []byte, label string) (*Envelope, error) {
+	ephemeral, err := e.key.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := ephemeral.ECDH(e.key)
+	if err != nil {
+		return nil, err
+	}
+
+	pub := ephemeral.PublicKey().Bytes()
+	key, err := eciesKey(e.curve, secret, pub, e.key.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealGCM(key, data, []byte(label))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		Method:  MethodECIES,
+		Params:  map[string]string{"curve": e.curve},
+		Payload: append(pub, sealed...),
+	}, nil
+}
+
+// decryptECIES opens an ECIES envelope with the recipient key
+func decryptECIES(priv *ecdh.PrivateKey, env *Envelope, label string) ([]byte, error) {
+	name := env.Param("curve")
+	curve, ok := eciesCurves[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown curve %q", ErrMalformedEnvelope, name)
+	}
+	if priv.Curve() != curve {
+		return nil, ErrWrongKey
+	}
+
+	size := len(priv.PublicKey().Bytes())
+	if len(env.Payload) < size {
+		return nil, fmt.Errorf("%w: payload too short", ErrMalformedEnvelope)
+	}
+	ephemeral, err := curve.NewPublicKey(env.Payload[:size])
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ephemeral key: %v", ErrMalformedEnvelope, err)
+	}
+	secret, err := priv.ECDH(ephemeral)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+
+	key, err := eciesKey(name, secret, env.Payload[:size], priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	b, err := openGCM(key, env.Payload[size:], []byte(label))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return b, nil
+}
+
+// eciesKey derives the AES key from the ECDH shared secret, binding it to
+// both public keys
+func eciesKey(curve string, secret, ephemeral, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	key := make([]byte, dataKeySize)
+	_, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte("smithy ecies "+curve)), key)
+	return key, err
+}
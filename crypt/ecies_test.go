@@ -0,0 +1,121 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+// generatePair writes a new key pair for algorithm and returns an
+// Encrypter for the public key and a Decrypter for the private key
+func generatePair(t *testing.T, algorithm string) (crypt.Encrypter, crypt.Decrypter) {
+	t.Helper()
+	dir := t.TempDir()
+	pub, priv := filepath.Join(dir, "public.key"), filepath.Join(dir, "private.key")
+	err := crypt.Generate(pub, priv, algorithm, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := crypt.LoadEncrypter(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e, crypt.NewDecrypter(crypt.FileKey(priv))
+}
+
+var eciesAlgorithms = []string{crypt.AlgorithmP256, crypt.AlgorithmP384, crypt.AlgorithmEd25519}
+
+func TestECIESRoundTrip(t *testing.T) {
+	for _, algorithm := range eciesAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			e, d := generatePair(t, algorithm)
+			for _, plaintext := range []string{"", "hunter2"} {
+				env, err := e.Encrypt([]byte(plaintext), "label")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if env.Method != crypt.MethodECIES {
+					t.Errorf("method %s, want %s", env.Method, crypt.MethodECIES)
+				}
+				b, err := crypt.DecryptWith(d, env.String(), "label")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != plaintext {
+					t.Errorf("decrypted %q, want %q", b, plaintext)
+				}
+			}
+		})
+	}
+}
+
+func TestECIESWrongKey(t *testing.T) {
+	for _, algorithm := range eciesAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			e, d := generatePair(t, algorithm)
+			_, other := generatePair(t, algorithm)
+			s, err := crypt.EncryptWith(e, []byte("hunter2"), "label")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = crypt.DecryptWith(other, s, "label")
+			if !errors.Is(err, crypt.ErrWrongKey) {
+				t.Errorf("other key: want ErrWrongKey, got %v", err)
+			}
+			_, err = crypt.DecryptWith(d, s, "other label")
+			if !errors.Is(err, crypt.ErrWrongKey) {
+				t.Errorf("other label: want ErrWrongKey, got %v", err)
+			}
+		})
+	}
+
+	// a value for one curve cannot be opened with a key on another
+	e, _ := generatePair(t, crypt.AlgorithmP256)
+	_, d := generatePair(t, crypt.AlgorithmP384)
+	s, err := crypt.EncryptWith(e, []byte("hunter2"), "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = crypt.DecryptWith(d, s, "label")
+	if !errors.Is(err, crypt.ErrWrongKey) {
+		t.Errorf("other curve: want ErrWrongKey, got %v", err)
+	}
+}
+
+func TestECIESTruncated(t *testing.T) {
+	for _, algorithm := range eciesAlgorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			e, d := generatePair(t, algorithm)
+			env, err := e.Encrypt([]byte("hunter2"), "label")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload := env.Payload
+			for n := 0; n < len(payload); n++ {
+				env.Payload = payload[:n]
+				_, err := d.Decrypt(env, "label")
+				if !errors.Is(err, crypt.ErrWrongKey) && !errors.Is(err, crypt.ErrMalformedEnvelope) {
+					t.Fatalf("payload cut to %d bytes: got %v", n, err)
+				}
+			}
+		})
+	}
+}
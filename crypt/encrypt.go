@@ -14,41 +14,27 @@
 
 package crypt
 
-import (
-	"crypto/rand"
-	"crypto/rsa"
-	"crypto/sha256"
-	"encoding/base64"
-
-	log "github.com/Sirupsen/logrus"
-)
-
 // EncryptToString encrypts data and encodes it to a
 // standard base64 encoding, as defined in RFC 4648.
 func EncryptToString(data []byte, label string, file string) (string, error) {
-	ev, err := Encrypt(data, label, file)
+	e, err := LoadEncrypter(file)
 	if err != nil {
-		return string(ev), err
+		return "", err
 	}
-	s := "ENC[" + base64.StdEncoding.EncodeToString(ev) + "]"
-	return s, nil
+	return EncryptWith(e, data, label)
 }
 
 // Encrypt will encrypt the data string using the PEM public
 // key extracted from the file
 func Encrypt(data []byte, label string, file string) ([]byte, error) {
-	var pubkey rsa.PublicKey
-	err := loadKey(file, &pubkey)
+	e, err := LoadEncrypter(file)
 	if err != nil {
-		log.WithField("file", file).WithError(err).Error("could not load public key")
 		return nil, err
 	}
 
-	encryptedValue, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &pubkey, data, []byte(label))
+	env, err := e.Encrypt(data, label)
 	if err != nil {
-		log.WithError(err).Error("could not encrypt data")
 		return nil, err
 	}
-
-	return encryptedValue, nil
+	return env.Payload, nil
 }
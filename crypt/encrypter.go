@@ -0,0 +1,98 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+)
+
+// Encrypter encrypts a value into an envelope. The same label must be
+// given when decrypting.
+type Encrypter interface {
+	Encrypt(data []byte, label string) (*Envelope, error)
+}
+
+// EncryptWith encrypts data with e and formats the resulting envelope
+func EncryptWith(e Encrypter, data []byte, label string) (string, error) {
+	env, err := e.Encrypt(data, label)
+	if err != nil {
+		return "", err
+	}
+	return env.String(), nil
+}
+
+// RSAEncrypter encrypts values with RSA-OAEP using SHA-256
+type RSAEncrypter struct {
+	key *rsa.PublicKey
+}
+
+// NewRSAEncrypter returns an Encrypter for an RSA public key
+func NewRSAEncrypter(key *rsa.PublicKey) *RSAEncrypter {
+	return &RSAEncrypter{key: key}
+}
+
+// Encrypt encrypts data with the public key
+func (e *RSAEncrypter) Encrypt(data []byte, label string) (*Envelope, error) {
+	b, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, e.key, data, []byte(label))
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{Method: MethodRSA, Payload: b}, nil
+}
+
+// LoadEncrypter returns an Encrypter for the public key or PEM
+// certificate stored in file
+func LoadEncrypter(file string) (Encrypter, error) {
+	if b, err := ioutil.ReadFile(file); err == nil && isCertificate(b) {
+		return LoadCertEncrypter(file)
+	}
+
+	key, err := LoadPublicKey(file)
+	if err != nil {
+		return nil, err
+	}
+	return newEncrypter(key, file)
+}
+
+// newEncrypter picks the Encrypter matching the type of a public key
+func newEncrypter(key crypto.PublicKey, name string) (Encrypter, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return NewRSAEncrypter(k), nil
+	case *ecdh.PublicKey:
+		return NewECIESEncrypter(k)
+	case *ecdsa.PublicKey:
+		x, err := k.ECDH()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return NewECIESEncrypter(x)
+	case ed25519.PublicKey:
+		x, err := ed25519PublicKeyToECDH(k)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		return NewECIESEncrypter(x)
+	}
+	return nil, fmt.Errorf("%s: unsupported public key type %T", name, key)
+}
@@ -0,0 +1,206 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	envelopePrefix = "ENC["
+	envelopeSuffix = "]"
+)
+
+// MethodRSA identifies values encrypted with RSA-OAEP. It is the method
+// of envelopes without a header.
+const MethodRSA = "rsa"
+
+// IsSecretKeyMethod reports whether values of method can only be made by
+// someone holding a secret, the siv data key or access to the KMS key.
+// Anyone with the public key can make values of the other methods.
+func IsSecretKeyMethod(method string) bool {
+	return method == MethodSIV || method == MethodKMS
+}
+
+var (
+	methodPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	paramKey      = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	paramValue    = regexp.MustCompile(`^[A-Za-z0-9._~+/=-]*$`)
+)
+
+// Envelope is the parsed form of an encrypted value. Values written by
+// earlier versions of smithy hold a standard base64 RSA-OAEP ciphertext:
+//
+//	ENC[<base64>]
+//
+// Other values name their method and may carry parameters in a header:
+//
+//	ENC[<method>,<key>=<value>,...:<base64>]
+type Envelope struct {
+	Method  string
+	Params  map[string]string
+	Payload []byte
+}
+
+type parseOptions struct {
+	whitespace     bool
+	requireBinding bool
+}
+
+// ParseOption changes how ParseEnvelope treats its input
+type ParseOption func(*parseOptions)
+
+// AllowWhitespace accepts whitespace around and inside the envelope, such
+// as base64 wrapped over several lines
+func AllowWhitespace() ParseOption {
+	return func(o *parseOptions) {
+		o.whitespace = true
+	}
+}
+
+// RequireBinding rejects values that are not bound to their location
+// with ErrNotBound
+func RequireBinding() ParseOption {
+	return func(o *parseOptions) {
+		o.requireBinding = true
+	}
+}
+
+// IsEncrypted reports whether s is meant to be an encrypted value. The
+// envelope may still be malformed.
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, envelopePrefix)
+}
+
+// ParseEnvelope parses an ENC[...] value. It returns ErrNotEncrypted if s
+// is not an envelope at all and ErrMalformedEnvelope describing the
+// problem if it cannot be parsed.
+func ParseEnvelope(s string, opts ...ParseOption) (*Envelope, error) {
+	var o parseOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.whitespace {
+		s = strings.TrimSpace(s)
+	}
+	if !IsEncrypted(s) {
+		return nil, ErrNotEncrypted
+	}
+	if !strings.HasSuffix(s, envelopeSuffix) || len(s) < len(envelopePrefix)+len(envelopeSuffix) {
+		return nil, malformed("missing closing %q", envelopeSuffix)
+	}
+
+	inner := s[len(envelopePrefix) : len(s)-len(envelopeSuffix)]
+	if o.whitespace {
+		inner = strings.Join(strings.Fields(inner), "")
+	}
+
+	env := &Envelope{Method: MethodRSA}
+	body := inner
+	if i := strings.IndexByte(inner, ':'); i >= 0 {
+		var err error
+		env.Method, env.Params, err = parseHeader(inner[:i])
+		if err != nil {
+			return nil, err
+		}
+		body = inner[i+1:]
+	}
+
+	if body == "" {
+		return nil, malformed("empty payload")
+	}
+	if strings.ContainsAny(body, "[]:,") {
+		return nil, malformed("unexpected character in payload")
+	}
+	// base64 decoding skips newlines, which strict parsing must not accept
+	if strings.ContainsAny(body, " \t\r\n") {
+		return nil, malformed("whitespace in payload")
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, malformed("invalid base64 payload: %v", err)
+	}
+	env.Payload = payload
+
+	if o.requireBinding && env.Param(ParamBind) == "" {
+		return nil, ErrNotBound
+	}
+	return env, nil
+}
+
+// parseHeader splits "method,key=value,..." into its parts
+func parseHeader(header string) (string, map[string]string, error) {
+	fields := strings.Split(header, ",")
+	method := fields[0]
+	if !methodPattern.MatchString(method) {
+		return "", nil, malformed("invalid method %q", method)
+	}
+
+	var params map[string]string
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || !paramKey.MatchString(kv[0]) || !paramValue.MatchString(kv[1]) {
+			return "", nil, malformed("invalid parameter %q", field)
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		if _, ok := params[kv[0]]; ok {
+			return "", nil, malformed("duplicate parameter %q", kv[0])
+		}
+		params[kv[0]] = kv[1]
+	}
+	return method, params, nil
+}
+
+// Param returns the value of a header parameter, or "" if it is not set
+func (e *Envelope) Param(key string) string {
+	return e.Params[key]
+}
+
+// String formats the envelope. RSA envelopes without parameters use the
+// headerless form so they can be read by earlier versions of smithy.
+func (e *Envelope) String() string {
+	payload := base64.StdEncoding.EncodeToString(e.Payload)
+	method := e.Method
+	if method == "" {
+		method = MethodRSA
+	}
+	if method == MethodRSA && len(e.Params) == 0 {
+		return envelopePrefix + payload + envelopeSuffix
+	}
+
+	keys := make([]string, 0, len(e.Params))
+	for k := range e.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	header := method
+	for _, k := range keys {
+		header += "," + k + "=" + e.Params[k]
+	}
+	return envelopePrefix + header + ":" + payload + envelopeSuffix
+}
+
+func malformed(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrMalformedEnvelope, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,114 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func FuzzParseEnvelope(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"ENC",
+		"ENC[",
+		"ENC[]",
+		"ENCODED",
+		"ENC[[]]",
+		"ENC[:]",
+		"ENC[rsa:]",
+		"ENC[aGVsbG8=]",
+		"ENC[rsa:aGVsbG8=]",
+		"ENC[kms,kms=vault,key=smithy:aGVsbG8=]",
+		"ENC[ecies,curve=x25519:aGVsbG8=]",
+		"ENC[siv,bind=path,key=5fcfcc419edaaf75:aGVsbG8=]",
+		"ENC[rsa,a=1,a=2:aGVsbG8=]",
+		"ENC[rsa,=1:aGVsbG8=]",
+		"ENC[RSA:aGVsbG8=]",
+		"ENC[rsa,cert=c4a2:aGVs\n  bG8=]",
+		"  ENC[aGVs bG8=]\n",
+		"ENC[aGVsbG8]",
+		"ENC[a:b:c]",
+		"ENC[\x00:\xff]",
+	} {
+		f.Add(seed, false)
+		f.Add(seed, true)
+	}
+
+	f.Fuzz(func(t *testing.T, s string, whitespace bool) {
+		var opts []ParseOption
+		if whitespace {
+			opts = append(opts, AllowWhitespace())
+		}
+
+		env, err := ParseEnvelope(s, opts...)
+		if err != nil {
+			if env != nil {
+				t.Fatalf("ParseEnvelope(%q) returned an envelope with error %v", s, err)
+			}
+			if !errors.Is(err, ErrNotEncrypted) && !errors.Is(err, ErrMalformedEnvelope) {
+				t.Fatalf("ParseEnvelope(%q) returned untyped error %v", s, err)
+			}
+			return
+		}
+
+		// a parsed envelope formats to a strict envelope parsing back to it
+		again, err := ParseEnvelope(env.String())
+		if err != nil {
+			t.Fatalf("ParseEnvelope(%q) of formatted %q: %v", env.String(), s, err)
+		}
+		if !reflect.DeepEqual(env, again) {
+			t.Fatalf("round trip of %q: got %#v, want %#v", s, again, env)
+		}
+	})
+}
+
+func TestParseEnvelope(t *testing.T) {
+	tests := []struct {
+		s      string
+		opts   []ParseOption
+		method string
+		params map[string]string
+		err    error
+	}{
+		{s: "ENC[aGVsbG8=]", method: MethodRSA},
+		{s: "ENC[kms,key=k,kms=vault:aGVsbG8=]", method: MethodKMS, params: map[string]string{"kms": "vault", "key": "k"}},
+		{s: "ENCODED", err: ErrNotEncrypted},
+		{s: "ENC[", err: ErrMalformedEnvelope},
+		{s: "ENC[]", err: ErrMalformedEnvelope},
+		{s: "ENC[rsa,a=1,a=2:aGVsbG8=]", err: ErrMalformedEnvelope},
+		{s: "ENC[aGVs\nbG8=]", err: ErrMalformedEnvelope},
+		{s: " ENC[aGVs\n bG8=]\n", opts: []ParseOption{AllowWhitespace()}, method: MethodRSA},
+		{s: "ENC[aGVsbG8=]", opts: []ParseOption{RequireBinding()}, err: ErrNotBound},
+	}
+
+	for _, tt := range tests {
+		env, err := ParseEnvelope(tt.s, tt.opts...)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ParseEnvelope(%q) error = %v, want %v", tt.s, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseEnvelope(%q): %v", tt.s, err)
+			continue
+		}
+		if env.Method != tt.method || !reflect.DeepEqual(env.Params, tt.params) || string(env.Payload) != "hello" {
+			t.Errorf("ParseEnvelope(%q) = %#v", tt.s, env)
+		}
+	}
+}
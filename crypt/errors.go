@@ -0,0 +1,45 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import "errors"
+
+// Errors returned by the crypt package. They may be wrapped with more
+// context, so compare using errors.Is.
+var (
+	// ErrNotEncrypted is returned when a value is not an ENC[...] envelope
+	ErrNotEncrypted = errors.New("value is not encrypted")
+	// ErrMalformedEnvelope is returned when an ENC[...] envelope cannot be parsed
+	ErrMalformedEnvelope = errors.New("malformed encrypted value")
+	// ErrKeyNotFound is returned when a key cannot be found or read
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrWrongKey is returned when a value was not encrypted for the key,
+	// or with a different label
+	ErrWrongKey = errors.New("value cannot be decrypted with this key")
+	// ErrPassphraseRequired is returned when a protected key is loaded
+	// without a way to ask for its passphrase
+	ErrPassphraseRequired = errors.New("private key is protected by a passphrase")
+	// ErrIncorrectPassphrase is returned when a protected key cannot be
+	// unlocked with the passphrase given
+	ErrIncorrectPassphrase = errors.New("incorrect passphrase")
+	// ErrInsecureKey is returned when a private key file can be read by
+	// users other than its owner
+	ErrInsecureKey = errors.New("unprotected private key file")
+	// ErrNotBound is returned when a value must be bound to its location
+	// but is not
+	ErrNotBound = errors.New("value is not bound to its field path")
+	// ErrBadSignature is returned when a signature does not match the data
+	ErrBadSignature = errors.New("signature does not match")
+)
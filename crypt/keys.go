@@ -1,44 +1,132 @@
 package crypt
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/gob"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"runtime"
+
+	"github.com/mshindle/smithy/internal/safefile"
+)
+
+// AllowInsecureKeys disables the check refusing private key files that
+// are readable by group or others, or owned by another user
+var AllowInsecureKeys bool
+
+// PEM block types written by smithy
+const (
+	pemPrivateKey = "PRIVATE KEY"
+	pemPublicKey  = "PUBLIC KEY"
 )
 
-// Saves a key using gob serialization.
-func saveKey(filename string, key interface{}) error {
-	outfile, err := os.Create(filename)
+// WritePrivateKey saves a private key as PKCS #8 PEM, encrypted if
+// passphrase is not empty. The file is replaced atomically and is only
+// readable by its owner.
+func WritePrivateKey(filename string, key crypto.PrivateKey, passphrase []byte) error {
+	b, err := MarshalPrivateKey(key, passphrase)
 	if err != nil {
 		return err
 	}
-	defer outfile.Close()
-
-	encoder := gob.NewEncoder(outfile)
-	return encoder.Encode(key)
+	return safefile.WriteFile(filename, 0600, safefile.Bytes(b))
 }
 
-// Loads a key from filename into key
-func loadKey(filename string, key interface{}) error {
-	infile, err := os.Open(filename)
+// Saves a public key as PKIX PEM
+func savePublicKey(filename string, key crypto.PublicKey) error {
+	der, err := x509.MarshalPKIXPublicKey(key)
 	if err != nil {
 		return err
 	}
-	defer infile.Close()
+	return safefile.WriteFile(filename, 0644, safefile.Bytes(pem.EncodeToMemory(&pem.Block{Type: pemPublicKey, Bytes: der})))
+}
+
+// checkKeyPermissions refuses private key files that other users can
+// read or write, or that are owned by another user, as OpenSSH does
+func checkKeyPermissions(filename string, fi os.FileInfo) error {
+	if AllowInsecureKeys || runtime.GOOS == "windows" {
+		return nil
+	}
+	if perm := fi.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%w: permissions %04o for %s are too open, it must not be accessible by others", ErrInsecureKey, perm, filename)
+	}
+	if uid, ok := fileOwner(fi); ok && uid != os.Getuid() {
+		return fmt.Errorf("%w: %s is owned by uid %d, not the current user", ErrInsecureKey, filename, uid)
+	}
+	return nil
+}
 
-	decoder := gob.NewDecoder(infile)
-	return decoder.Decode(key)
+// ParsePrivateKey parses a PEM encoded PKCS #1, SEC 1 or PKCS #8 private key.
+// Keys written by earlier versions of smithy using gob are also accepted.
+func ParsePrivateKey(b []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		var key rsa.PrivateKey
+		err := gob.NewDecoder(bytes.NewReader(b)).Decode(&key)
+		if err != nil {
+			return nil, errors.New("data is neither PEM encoded nor a smithy gob key")
+		}
+		key.Precompute()
+		return &key, nil
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case pemPrivateKey:
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case pemEncryptedPrivateKey:
+		return nil, ErrPassphraseRequired
+	case pemOpenSSHPrivateKey:
+		return parseOpenSSHPrivateKey(b, nil)
+	}
+	return nil, fmt.Errorf("unsupported private key type %q", block.Type)
 }
 
-//func extractPemDataBlock(file string) (*pem.Block, error) {
-//	fileContents, err := ioutil.ReadFile(file)
-//	if err != nil {
-//		log.WithField("file", file).Error("could not read file")
-//		return nil, err
-//	}
-//	block, _ := pem.Decode(fileContents)
-//	if block == nil {
-//		log.WithField("file", file).Error("could not decode file contents - not PEM encoded")
-//		return nil, errors.New("data not PEM encoded")
-//	}
-//	return block, nil
-//}
+// ParsePublicKey parses a PEM encoded PKIX or PKCS #1 public key, or an
+// OpenSSH public key in authorized_keys format. Keys written by earlier
+// versions of smithy using gob are also accepted.
+func ParsePublicKey(b []byte) (crypto.PublicKey, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("ssh-")) {
+		return parseAuthorizedKey(b)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		var key rsa.PublicKey
+		err := gob.NewDecoder(bytes.NewReader(b)).Decode(&key)
+		if err != nil {
+			return nil, errors.New("data is neither PEM encoded nor a smithy gob key")
+		}
+		return &key, nil
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case pemPublicKey:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported public key type %q", block.Type)
+}
+
+// LoadPublicKey reads the public key stored in filename
+func LoadPublicKey(filename string) (crypto.PublicKey, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrKeyNotFound, err)
+	}
+
+	key, err := ParsePublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return key, nil
+}
@@ -0,0 +1,179 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// MethodKMS identifies values whose data key is wrapped by a KMS
+const MethodKMS = "kms"
+
+// dataKeySize is the size of the AES-256 data keys wrapped by a KMS
+const dataKeySize = 32
+
+// KMS wraps and unwraps data keys with a key held by a remote key
+// management service, so that the master key never leaves the service.
+type KMS interface {
+	// Name identifies the backend in envelopes, such as "vault"
+	Name() string
+	// KeyID identifies the wrapping key in envelopes
+	KeyID() string
+	// WrapKey encrypts a data key with the wrapping key
+	WrapKey(dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped by the key named keyID
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KMSEncrypter encrypts each value with a fresh AES-256-GCM data key and
+// stores the data key, wrapped by the KMS, alongside the ciphertext
+type KMSEncrypter struct {
+	kms KMS
+}
+
+// NewKMSEncrypter returns an Encrypter wrapping data keys with kms
+func NewKMSEncrypter(kms KMS) *KMSEncrypter {
+	return &KMSEncrypter{kms: kms}
+}
+
+// Encrypt seals data under a new data key. The payload holds the length
+// of the wrapped key, the wrapped key, the nonce and the ciphertext.
+func (e *KMSEncrypter) Encrypt(data []byte, label string) (*Envelope, error) {
+	dataKey := make([]byte, dataKeySize)
+	_, err := randRead(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := e.kms.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("%s: wrapped key too long", e.kms.Name())
+	}
+
+	sealed, err := sealGCM(dataKey, data, []byte(label))
+	if err != nil {
+		return nil, err
+	}
+
+	payload := make([]byte, 2, 2+len(wrapped)+len(sealed))
+	binary.BigEndian.PutUint16(payload, uint16(len(wrapped)))
+	payload = append(payload, wrapped...)
+	payload = append(payload, sealed...)
+
+	return &Envelope{
+		Method:  MethodKMS,
+		Params:  map[string]string{"kms": e.kms.Name(), "key": e.kms.KeyID()},
+		Payload: payload,
+	}, nil
+}
+
+// Decrypter returns a Decrypter for the values e encrypts
+func (e *KMSEncrypter) Decrypter() Decrypter {
+	return NewKMSDecrypter(e.kms)
+}
+
+// KMSDecrypter unwraps data keys with the KMS named in each envelope
+type KMSDecrypter struct {
+	backends map[string]KMS
+}
+
+// NewKMSDecrypter returns a Decrypter using the given KMS backends
+func NewKMSDecrypter(backends ...KMS) *KMSDecrypter {
+	d := &KMSDecrypter{backends: make(map[string]KMS)}
+	for _, kms := range backends {
+		d.backends[kms.Name()] = kms
+	}
+	return d
+}
+
+// Decrypt unwraps the data key and opens the ciphertext
+func (d *KMSDecrypter) Decrypt(env *Envelope, label string) ([]byte, error) {
+	if env.Method != MethodKMS {
+		return nil, fmt.Errorf("%w: unknown method %q", ErrMalformedEnvelope, env.Method)
+	}
+
+	kms, ok := d.backends[env.Param("kms")]
+	if !ok {
+		return nil, fmt.Errorf("%w: no KMS backend %q configured", ErrKeyNotFound, env.Param("kms"))
+	}
+
+	p := env.Payload
+	if len(p) < 2 || len(p) < 2+int(binary.BigEndian.Uint16(p)) {
+		return nil, fmt.Errorf("%w: truncated KMS payload", ErrMalformedEnvelope)
+	}
+	n := 2 + int(binary.BigEndian.Uint16(p))
+
+	dataKey, err := kms.UnwrapKey(env.Param("key"), p[2:n])
+	if err != nil {
+		return nil, err
+	}
+	return openGCM(dataKey, p[n:], []byte(label))
+}
+
+// randRead fills b with random bytes
+func randRead(b []byte) (int, error) {
+	return io.ReadFull(rand.Reader, b)
+}
+
+// sealGCM encrypts data with AES-GCM, returning the nonce followed by the
+// ciphertext
+func sealGCM(key []byte, data []byte, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(data)+aead.Overhead())
+	_, err = randRead(nonce)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, data, ad), nil
+}
+
+// openGCM reverses sealGCM
+func openGCM(key []byte, sealed []byte, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("%w: ciphertext too short", ErrMalformedEnvelope)
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	b, err := aead.Open(nil, nonce, ciphertext, ad)
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	return b, nil
+}
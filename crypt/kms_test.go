@@ -0,0 +1,95 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/crypt/kmstest"
+)
+
+func TestVaultTransitRoundTrip(t *testing.T) {
+	server := kmstest.NewVaultServer("token")
+	defer server.Close()
+
+	transit := server.Transit("smithy")
+	s, err := crypt.EncryptWith(crypt.NewKMSEncrypter(transit), []byte("hunter2"), "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(s, "ENC[kms,key=smithy,kms=vault:") {
+		t.Errorf("unexpected envelope %s", s)
+	}
+
+	d := crypt.NewKMSDecrypter(transit)
+	b, err := crypt.DecryptWith(d, s, "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hunter2" {
+		t.Errorf("decrypted %q, want %q", b, "hunter2")
+	}
+
+	_, err = crypt.DecryptWith(d, s, "other")
+	if !errors.Is(err, crypt.ErrWrongKey) {
+		t.Errorf("decrypting with another label: %v, want ErrWrongKey", err)
+	}
+
+	_, err = crypt.DecryptWith(crypt.NewKMSDecrypter(server.Transit("other")), s, "label")
+	if !errors.Is(err, crypt.ErrKeyNotFound) {
+		t.Errorf("decrypting with another configured key: %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestVaultTransitRejectsEnvelopeKey(t *testing.T) {
+	server := kmstest.NewVaultServer("token")
+	defer server.Close()
+
+	transit := server.Transit("smithy")
+	s, err := crypt.EncryptWith(crypt.NewKMSEncrypter(transit), []byte("hunter2"), "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := len(server.Paths())
+
+	for _, key := range []string{"other", "../../sys/seal", "smithy/../../sys/seal"} {
+		crafted := strings.Replace(s, "key=smithy", "key="+key, 1)
+		_, err = crypt.DecryptWith(crypt.NewKMSDecrypter(transit), crafted, "label")
+		if !errors.Is(err, crypt.ErrKeyNotFound) {
+			t.Errorf("key %q: %v, want ErrKeyNotFound", key, err)
+		}
+	}
+	if paths := server.Paths(); len(paths) != before {
+		t.Errorf("crafted keys reached vault: %v", paths[before:])
+	}
+}
+
+func TestVaultTransitEscapesPath(t *testing.T) {
+	server := kmstest.NewVaultServer("token")
+	defer server.Close()
+
+	transit := server.Transit("a/../b")
+	_, err := transit.WrapKey(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := server.Paths()
+	if want := "/v1/transit/encrypt/a%2F..%2Fb"; len(paths) != 1 || paths[0] != want {
+		t.Errorf("requested %v, want %s", paths, want)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kmstest provides in-process fakes of remote key management
+// services so that KMS backed encryption can be exercised offline.
+package kmstest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+const ciphertextPrefix = "vault:v1:"
+
+// VaultServer is a fake Vault serving the transit encrypt and decrypt
+// endpoints. Keys are created on first use, like a transit mount with
+// auto-creation allowed.
+type VaultServer struct {
+	*httptest.Server
+	Token string
+
+	mu    sync.Mutex
+	keys  map[string]cipher.AEAD
+	paths []string
+}
+
+// NewVaultServer starts a fake Vault accepting token
+func NewVaultServer(token string) *VaultServer {
+	s := &VaultServer{Token: token, keys: make(map[string]cipher.AEAD)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Transit returns a client for key on this server
+func (s *VaultServer) Transit(key string) *crypt.VaultTransit {
+	v := crypt.NewVaultTransit(s.URL, s.Token, key)
+	v.Client = s.Client()
+	return v
+}
+
+// Paths returns the escaped paths of all requests received so far
+func (s *VaultServer) Paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.paths...)
+}
+
+func (s *VaultServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.paths = append(s.paths, r.URL.EscapedPath())
+	s.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != s.Token {
+		writeResponse(w, http.StatusForbidden, nil, "permission denied")
+		return
+	}
+
+	// expect /v1/<mount>/<op>/<key>, each segment escaped
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	for i := range parts {
+		parts[i], _ = url.PathUnescape(parts[i])
+	}
+	if r.Method != http.MethodPost || len(parts) != 4 || parts[0] != "v1" {
+		writeResponse(w, http.StatusNotFound, nil, "unsupported path")
+		return
+	}
+
+	var req struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	aead, err := s.key(parts[3])
+	if err != nil {
+		writeResponse(w, http.StatusInternalServerError, nil, err.Error())
+		return
+	}
+
+	switch parts[2] {
+	case "encrypt":
+		plaintext, err := base64.StdEncoding.DecodeString(req.Plaintext)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, nil, "plaintext is not base64")
+			return
+		}
+		nonce := make([]byte, aead.NonceSize())
+		io.ReadFull(rand.Reader, nonce)
+		sealed := aead.Seal(nonce, nonce, plaintext, nil)
+		writeResponse(w, http.StatusOK, map[string]string{
+			"ciphertext": ciphertextPrefix + base64.StdEncoding.EncodeToString(sealed),
+		}, "")
+	case "decrypt":
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(req.Ciphertext, ciphertextPrefix))
+		if err != nil || !strings.HasPrefix(req.Ciphertext, ciphertextPrefix) || len(sealed) < aead.NonceSize() {
+			writeResponse(w, http.StatusBadRequest, nil, "invalid ciphertext")
+			return
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+		if err != nil {
+			writeResponse(w, http.StatusBadRequest, nil, "cipher: message authentication failed")
+			return
+		}
+		writeResponse(w, http.StatusOK, map[string]string{
+			"plaintext": base64.StdEncoding.EncodeToString(plaintext),
+		}, "")
+	default:
+		writeResponse(w, http.StatusNotFound, nil, "unsupported operation")
+	}
+}
+
+// key returns the cipher for a named key, creating it if needed
+func (s *VaultServer) key(name string) (cipher.AEAD, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if aead, ok := s.keys[name]; ok {
+		return aead, nil
+	}
+
+	k := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, k)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	s.keys[name] = aead
+	return aead, nil
+}
+
+func writeResponse(w http.ResponseWriter, status int, data interface{}, errMsg string) {
+	body := map[string]interface{}{}
+	if data != nil {
+		body["data"] = data
+	}
+	if errMsg != "" {
+		body["errors"] = []string{errMsg}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,168 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PassphraseEnv is the environment variable holding a key passphrase
+const PassphraseEnv = "SMITHY_KEY_PASSPHRASE"
+
+// pemEncryptedPrivateKey is the PEM type of passphrase protected keys. The
+// body is a PKCS #8 key sealed with AES-256-GCM under a key derived from
+// the passphrase with scrypt; the parameters are kept in PEM headers.
+const pemEncryptedPrivateKey = "SMITHY ENCRYPTED PRIVATE KEY"
+
+// scrypt parameters for new keys
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Limits on the scrypt parameters of keys being read, which come from the
+// file itself. They allow for keys far harder than the defaults while
+// keeping a crafted key from using more than 1 GiB or hours of work.
+const (
+	maxScryptN      = 1 << 20
+	maxScryptR      = 32
+	maxScryptP      = 16
+	maxScryptMemory = 1 << 30
+)
+
+// PassphraseFunc returns the passphrase for the key described by name
+type PassphraseFunc func(name string) ([]byte, error)
+
+// EnvPassphrase returns a PassphraseFunc reading the environment variable env
+func EnvPassphrase(env string) PassphraseFunc {
+	return func(name string) ([]byte, error) {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is not set", ErrPassphraseRequired, env)
+		}
+		return []byte(v), nil
+	}
+}
+
+// IsEncryptedKey reports whether b holds a private key protected by a
+// smithy passphrase. Protected OpenSSH keys are recognized by
+// ParsePrivateKey returning ErrPassphraseRequired.
+func IsEncryptedKey(b []byte) bool {
+	block, _ := pem.Decode(b)
+	return block != nil && block.Type == pemEncryptedPrivateKey
+}
+
+// MarshalPrivateKey encodes key as PEM. When passphrase is not empty the
+// key is encrypted with it.
+func MarshalPrivateKey(key crypto.PrivateKey, passphrase []byte) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil
+	}
+
+	salt := make([]byte, 16)
+	_, err = randRead(salt)
+	if err != nil {
+		return nil, err
+	}
+	kek, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, dataKeySize)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealGCM(kek, der, []byte(pemEncryptedPrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type: pemEncryptedPrivateKey,
+		Headers: map[string]string{
+			"KDF":  "scrypt",
+			"Salt": hex.EncodeToString(salt),
+			"N":    strconv.Itoa(scryptN),
+			"R":    strconv.Itoa(scryptR),
+			"P":    strconv.Itoa(scryptP),
+		},
+		Bytes: sealed,
+	}), nil
+}
+
+// ParsePrivateKeyWithPassphrase parses a private key, decrypting it with
+// passphrase if it is protected
+func ParsePrivateKeyWithPassphrase(b []byte, passphrase []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block != nil && block.Type == pemOpenSSHPrivateKey {
+		return parseOpenSSHPrivateKey(b, passphrase)
+	}
+	if block == nil || block.Type != pemEncryptedPrivateKey {
+		return ParsePrivateKey(b)
+	}
+
+	if block.Headers["KDF"] != "scrypt" {
+		return nil, fmt.Errorf("unsupported key derivation %q", block.Headers["KDF"])
+	}
+	salt, err := hex.DecodeString(block.Headers["Salt"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid salt: %v", err)
+	}
+	var params [3]int
+	for i, name := range []string{"N", "R", "P"} {
+		params[i], err = strconv.Atoi(block.Headers[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrypt parameter %s", name)
+		}
+	}
+	err = checkScryptParams(params[0], params[1], params[2])
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := scrypt.Key(passphrase, salt, params[0], params[1], params[2], dataKeySize)
+	if err != nil {
+		return nil, err
+	}
+	der, err := openGCM(kek, block.Bytes, []byte(pemEncryptedPrivateKey))
+	if err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return x509.ParsePKCS8PrivateKey(der)
+}
+
+// checkScryptParams rejects scrypt parameters outside the limits
+func checkScryptParams(n, r, p int) error {
+	switch {
+	case n < 2 || n > maxScryptN || n&(n-1) != 0:
+		return fmt.Errorf("scrypt parameter N=%d must be a power of 2 no larger than %d", n, maxScryptN)
+	case r < 1 || r > maxScryptR:
+		return fmt.Errorf("scrypt parameter R=%d must be between 1 and %d", r, maxScryptR)
+	case p < 1 || p > maxScryptP:
+		return fmt.Errorf("scrypt parameter P=%d must be between 1 and %d", p, maxScryptP)
+	case 128*r*n > maxScryptMemory:
+		return fmt.Errorf("scrypt parameters N=%d R=%d need more than %d bytes", n, r, maxScryptMemory)
+	}
+	return nil
+}
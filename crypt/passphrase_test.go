@@ -0,0 +1,98 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt_test
+
+import (
+	"encoding/pem"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+// protectedKey returns a new Ed25519 key and its PEM encoding protected
+// by passphrase
+func protectedKey(t *testing.T, passphrase string) (interface{}, []byte) {
+	t.Helper()
+	key, err := crypt.GenerateKey(crypt.AlgorithmEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := crypt.MarshalPrivateKey(key, []byte(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, b
+}
+
+func TestPassphraseRoundTrip(t *testing.T) {
+	key, b := protectedKey(t, "correct horse")
+	if !crypt.IsEncryptedKey(b) {
+		t.Fatal("key is not protected")
+	}
+
+	got, err := crypt.ParsePrivateKeyWithPassphrase(b, []byte("correct horse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint(t, got) != fingerprint(t, key) {
+		t.Error("decrypted another key")
+	}
+}
+
+func TestPassphraseWrong(t *testing.T) {
+	_, b := protectedKey(t, "correct horse")
+
+	for _, passphrase := range []string{"", "correct horse ", "battery staple"} {
+		_, err := crypt.ParsePrivateKeyWithPassphrase(b, []byte(passphrase))
+		if !errors.Is(err, crypt.ErrIncorrectPassphrase) {
+			t.Errorf("passphrase %q: want ErrIncorrectPassphrase, got %v", passphrase, err)
+		}
+	}
+}
+
+// Crafted parameters must be refused before scrypt runs: the largest of
+// these would take gigabytes of memory and minutes to derive.
+func TestPassphraseRejectsScryptParameters(t *testing.T) {
+	_, b := protectedKey(t, "correct horse")
+	block, _ := pem.Decode(b)
+
+	for _, params := range []struct{ n, r, p string }{
+		{"2097152", "8", "1"},  // N above the limit
+		{"1048576", "16", "1"}, // 2 GiB of memory
+		{"1000", "8", "1"},     // N not a power of 2
+		{"1", "8", "1"},
+		{"32768", "64", "1"},
+		{"32768", "0", "1"},
+		{"32768", "8", "17"},
+		{"32768", "8", "0"},
+		{"32768", "8", "-1"},
+		{"1073741824", "8", "1"},
+	} {
+		crafted := *block
+		crafted.Headers = map[string]string{
+			"KDF":  block.Headers["KDF"],
+			"Salt": block.Headers["Salt"],
+			"N":    params.n,
+			"R":    params.r,
+			"P":    params.p,
+		}
+		_, err := crypt.ParsePrivateKeyWithPassphrase(pem.EncodeToMemory(&crafted), []byte("correct horse"))
+		if err == nil || !strings.Contains(err.Error(), "scrypt parameter") {
+			t.Errorf("N=%s R=%s P=%s: want the parameters rejected, got %v", params.n, params.r, params.p, err)
+		}
+	}
+}
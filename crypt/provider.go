@@ -0,0 +1,207 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// PrivateKeyEnv is the environment variable holding PEM private key content
+const PrivateKeyEnv = "SMITHY_PRIVATE_KEY"
+
+// KeyProvider supplies the encoded bytes of a private key
+type KeyProvider interface {
+	// KeyBytes returns the PEM (or legacy gob) encoded key
+	KeyBytes() ([]byte, error)
+	// String describes where the key comes from
+	String() string
+}
+
+// FileKey provides a key stored in a file
+type FileKey string
+
+// KeyBytes reads the key file after checking that only its owner can
+// access it
+func (f FileKey) KeyBytes() ([]byte, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	err = checkKeyPermissions(string(f), fi)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(file)
+}
+
+func (f FileKey) String() string {
+	return string(f)
+}
+
+// EnvKey provides a key held in an environment variable
+type EnvKey string
+
+// KeyBytes returns the content of the environment variable
+func (e EnvKey) KeyBytes() ([]byte, error) {
+	v, ok := os.LookupEnv(string(e))
+	if !ok || v == "" {
+		return nil, fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	return []byte(v), nil
+}
+
+func (e EnvKey) String() string {
+	return "env:" + string(e)
+}
+
+// ReaderKey provides a key read once from an io.Reader such as stdin
+type ReaderKey struct {
+	Name   string
+	Reader io.Reader
+}
+
+// KeyBytes reads everything from the reader
+func (r *ReaderKey) KeyBytes() ([]byte, error) {
+	return ioutil.ReadAll(r.Reader)
+}
+
+func (r *ReaderKey) String() string {
+	return r.Name
+}
+
+// StdinKey returns a provider reading the key from stdin
+func StdinKey() KeyProvider {
+	return &ReaderKey{Name: "stdin", Reader: os.Stdin}
+}
+
+// FDKey returns a provider reading the key from an inherited file descriptor
+func FDKey(fd uintptr) KeyProvider {
+	name := "fd:" + strconv.FormatUint(uint64(fd), 10)
+	return &ReaderKey{Name: name, Reader: os.NewFile(fd, name)}
+}
+
+// CommandKey provides a key printed on stdout by a shell command, such as
+// a password manager
+type CommandKey string
+
+// KeyBytes runs the command and returns its output
+func (c CommandKey) KeyBytes() ([]byte, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("/bin/sh", "-c", string(c))
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("key command failed: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+func (c CommandKey) String() string {
+	return "cmd:" + string(c)
+}
+
+// SharesKey provides the key shares held in several files, which the
+// KeyDecrypter combines into the private key in memory
+type SharesKey []FileKey
+
+// KeyBytes returns the contents of all the share files
+func (s SharesKey) KeyBytes() ([]byte, error) {
+	var b []byte
+	for _, f := range s {
+		share, err := f.KeyBytes()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, share...)
+		b = append(b, '\n')
+	}
+	return b, nil
+}
+
+func (s SharesKey) String() string {
+	files := make([]string, len(s))
+	for i, f := range s {
+		files[i] = string(f)
+	}
+	return "shares:" + strings.Join(files, ",")
+}
+
+// key source schemes understood by ParseKeyProvider
+var keySchemes = []string{"file:", "env:", "fd:", "cmd:", "shares:"}
+
+// HasKeyScheme reports whether spec names a key source rather than a plain path
+func HasKeyScheme(spec string) bool {
+	if spec == "-" || spec == "stdin" {
+		return true
+	}
+	for _, scheme := range keySchemes {
+		if strings.HasPrefix(spec, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseKeyProvider returns the provider described by spec:
+//
+//	file:PATH   or a plain PATH   a key file
+//	env:NAME                      PEM content in an environment variable
+//	stdin       or -              read from stdin
+//	fd:N                          read from file descriptor N
+//	cmd:COMMAND                   the output of a shell command
+//	shares:PATH,PATH,...          key shares combined in memory
+func ParseKeyProvider(spec string) (KeyProvider, error) {
+	switch {
+	case spec == "-" || spec == "stdin":
+		return StdinKey(), nil
+	case strings.HasPrefix(spec, "file:"):
+		return FileKey(strings.TrimPrefix(spec, "file:")), nil
+	case strings.HasPrefix(spec, "env:"):
+		return EnvKey(strings.TrimPrefix(spec, "env:")), nil
+	case strings.HasPrefix(spec, "fd:"):
+		fd, err := strconv.ParseUint(strings.TrimPrefix(spec, "fd:"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file descriptor in %q", spec)
+		}
+		return FDKey(uintptr(fd)), nil
+	case strings.HasPrefix(spec, "cmd:"):
+		return CommandKey(strings.TrimPrefix(spec, "cmd:")), nil
+	case strings.HasPrefix(spec, "shares:"):
+		var shares SharesKey
+		for _, f := range strings.Split(strings.TrimPrefix(spec, "shares:"), ",") {
+			if f != "" {
+				shares = append(shares, FileKey(f))
+			}
+		}
+		return shares, nil
+	case spec == "":
+		return nil, fmt.Errorf("%w: no private key configured", ErrKeyNotFound)
+	}
+	return FileKey(spec), nil
+}
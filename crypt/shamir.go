@@ -0,0 +1,286 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// pemKeyShare is the PEM type of a share of a private key
+const pemKeyShare = "SMITHY KEY SHARE"
+
+// KeyShare is one of the shares a private key is split into with
+// Shamir's secret sharing. Any Threshold shares of the same key rebuild
+// it; fewer reveal nothing about it.
+type KeyShare struct {
+	// Key is the fingerprint of the public key, identifying the shares
+	// that belong together
+	Key string
+	// Index is the number of the share, from 1 to Count
+	Index int
+	// Count is the number of shares the key was split into
+	Count int
+	// Threshold is the number of shares needed to rebuild the key
+	Threshold int
+	// Value is the share of the PKCS #8 encoded key
+	Value []byte
+}
+
+// SplitPrivateKey splits key into count shares, any threshold of which
+// rebuild it
+func SplitPrivateKey(key crypto.PrivateKey, count int, threshold int) ([]*KeyShare, error) {
+	if threshold < 2 || threshold > count || count > 255 {
+		return nil, fmt.Errorf("cannot split a key into %d shares with a threshold of %d", count, threshold)
+	}
+
+	pub, err := PublicKeyOf(key)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := KeyFingerprint(pub)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := splitSecret(der, count, threshold)
+	if err != nil {
+		return nil, err
+	}
+	shares := make([]*KeyShare, count)
+	for i, v := range values {
+		shares[i] = &KeyShare{Key: fingerprint, Index: i + 1, Count: count, Threshold: threshold, Value: v}
+	}
+	return shares, nil
+}
+
+// CombineKeyShares rebuilds a private key from at least Threshold
+// distinct shares
+func CombineKeyShares(shares []*KeyShare) (crypto.PrivateKey, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no key shares given")
+	}
+
+	first := shares[0]
+	xs := make([]byte, 0, len(shares))
+	ys := make([][]byte, 0, len(shares))
+	seen := make(map[int]bool)
+	for _, s := range shares {
+		err := s.check()
+		if err != nil {
+			return nil, err
+		}
+		if s.Key != first.Key || s.Count != first.Count || s.Threshold != first.Threshold || len(s.Value) != len(first.Value) {
+			return nil, errors.New("key shares belong to different keys")
+		}
+		if seen[s.Index] {
+			continue
+		}
+		seen[s.Index] = true
+		xs = append(xs, byte(s.Index))
+		ys = append(ys, s.Value)
+	}
+	if len(xs) < first.Threshold {
+		return nil, fmt.Errorf("%d of %d key shares needed, got %d", first.Threshold, first.Count, len(xs))
+	}
+
+	der := combineShares(xs[:first.Threshold], ys[:first.Threshold])
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("key shares do not combine to a key: %v", err)
+	}
+	pub, err := PublicKeyOf(key)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := KeyFingerprint(pub)
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != first.Key {
+		return nil, errors.New("key shares do not combine to the expected key")
+	}
+	return key, nil
+}
+
+// check makes sure the numbers of the share are consistent, so shares
+// cannot claim a threshold no secret was split with
+func (s *KeyShare) check() error {
+	switch {
+	case s.Threshold < 2 || s.Threshold > s.Count || s.Count > 255:
+		return fmt.Errorf("key share %d has a threshold of %d out of %d shares", s.Index, s.Threshold, s.Count)
+	case s.Index < 1 || s.Index > s.Count:
+		return fmt.Errorf("key share %d is not one of %d shares", s.Index, s.Count)
+	case len(s.Value) == 0:
+		return fmt.Errorf("key share %d is empty", s.Index)
+	}
+	return nil
+}
+
+// MarshalPEM encodes the share as PEM, suited to printing
+func (s *KeyShare) MarshalPEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type: pemKeyShare,
+		Headers: map[string]string{
+			"Key":       s.Key,
+			"Share":     strconv.Itoa(s.Index),
+			"Shares":    strconv.Itoa(s.Count),
+			"Threshold": strconv.Itoa(s.Threshold),
+		},
+		Bytes: append([]byte{byte(s.Index)}, s.Value...),
+	})
+}
+
+// IsKeyShare reports whether b holds PEM encoded key shares
+func IsKeyShare(b []byte) bool {
+	block, _ := pem.Decode(b)
+	return block != nil && block.Type == pemKeyShare
+}
+
+// ParseKeyShares parses every PEM encoded share in b
+func ParseKeyShares(b []byte) ([]*KeyShare, error) {
+	var shares []*KeyShare
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != pemKeyShare {
+			continue
+		}
+
+		s := &KeyShare{Key: block.Headers["Key"]}
+		var err error
+		for _, h := range []struct {
+			name string
+			v    *int
+		}{{"Share", &s.Index}, {"Shares", &s.Count}, {"Threshold", &s.Threshold}} {
+			*h.v, err = strconv.Atoi(block.Headers[h.name])
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s header in key share", h.name)
+			}
+		}
+		if len(block.Bytes) < 2 || int(block.Bytes[0]) != s.Index {
+			return nil, fmt.Errorf("key share %d is corrupt", s.Index)
+		}
+		s.Value = block.Bytes[1:]
+		err = s.check()
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, s)
+	}
+	if len(shares) == 0 {
+		return nil, errors.New("no key shares found")
+	}
+	return shares, nil
+}
+
+// combineKeyShareBytes rebuilds a private key from the PEM shares in b
+func combineKeyShareBytes(b []byte) (crypto.PrivateKey, error) {
+	shares, err := ParseKeyShares(b)
+	if err != nil {
+		return nil, err
+	}
+	return CombineKeyShares(shares)
+}
+
+// splitSecret splits secret byte by byte with random polynomials of
+// degree threshold-1 over GF(256), evaluated at x = 1..count
+func splitSecret(secret []byte, count int, threshold int) ([][]byte, error) {
+	coeffs := make([]byte, threshold)
+	shares := make([][]byte, count)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret))
+	}
+
+	for j, s := range secret {
+		_, err := randRead(coeffs[1:])
+		if err != nil {
+			return nil, err
+		}
+		coeffs[0] = s
+		for i := range shares {
+			x := byte(i + 1)
+			var y byte
+			for k := len(coeffs) - 1; k >= 0; k-- {
+				y = gfMul(y, x) ^ coeffs[k]
+			}
+			shares[i][j] = y
+		}
+	}
+	for i := range coeffs {
+		coeffs[i] = 0
+	}
+	return shares, nil
+}
+
+// combineShares interpolates the shares at x = 0
+func combineShares(xs []byte, ys [][]byte) []byte {
+	secret := make([]byte, len(ys[0]))
+	for i, xi := range xs {
+		// Lagrange basis polynomial for xi at 0
+		basis := byte(1)
+		for j, xj := range xs {
+			if i != j {
+				basis = gfMul(basis, gfDiv(xj, xj^xi))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= gfMul(ys[i][k], basis)
+		}
+	}
+	return secret
+}
+
+// log and exp tables of GF(256) with the AES polynomial and generator 3
+var gfLog, gfExp = gfTables()
+
+func gfTables() ([256]byte, [510]byte) {
+	var log [256]byte
+	var exp [510]byte
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+		// multiply by the generator 3
+		x ^= x<<1 ^ byte(int8(x)>>7)&0x1b
+	}
+	return log, exp
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
@@ -0,0 +1,185 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/pem"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+func fingerprint(t *testing.T, key crypto.PrivateKey) string {
+	t.Helper()
+	pub, err := crypt.PublicKeyOf(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := crypt.KeyFingerprint(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+func splitKey(t *testing.T, algorithm string, count, threshold int) (crypto.PrivateKey, []*crypt.KeyShare) {
+	t.Helper()
+	key, err := crypt.GenerateKey(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := crypt.SplitPrivateKey(key, count, threshold)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, shares
+}
+
+// marshalShares encodes the shares at the given positions as PEM
+func marshalShares(shares []*crypt.KeyShare, picks ...int) []byte {
+	var buf bytes.Buffer
+	for _, i := range picks {
+		buf.Write(shares[i].MarshalPEM())
+	}
+	return buf.Bytes()
+}
+
+func TestKeySharesRoundTrip(t *testing.T) {
+	for _, algorithm := range []string{crypt.AlgorithmRSA, crypt.AlgorithmEd25519} {
+		t.Run(algorithm, func(t *testing.T) {
+			key, shares := splitKey(t, algorithm, 5, 3)
+			want := fingerprint(t, key)
+
+			for _, picks := range [][]int{{0, 1, 2}, {2, 3, 4}, {4, 0, 2}, {1, 1, 3, 4}, {0, 1, 2, 3, 4}} {
+				parsed, err := crypt.ParseKeyShares(marshalShares(shares, picks...))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := crypt.CombineKeyShares(parsed)
+				if err != nil {
+					t.Fatalf("shares %v: %v", picks, err)
+				}
+				if fp := fingerprint(t, got); fp != want {
+					t.Errorf("shares %v rebuilt key %s, want %s", picks, fp, want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeySharesTooFew(t *testing.T) {
+	_, shares := splitKey(t, crypt.AlgorithmEd25519, 5, 3)
+
+	for _, picks := range [][]int{{0}, {0, 4}, {2, 2, 2}} {
+		parsed, err := crypt.ParseKeyShares(marshalShares(shares, picks...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = crypt.CombineKeyShares(parsed)
+		if err == nil {
+			t.Errorf("shares %v rebuilt a key below the threshold", picks)
+		}
+	}
+}
+
+// A corrupted share must never rebuild another key. Some bytes, such as
+// the PKCS #8 version, can change without changing the key.
+func TestKeySharesCorrupted(t *testing.T) {
+	key, shares := splitKey(t, crypt.AlgorithmEd25519, 3, 2)
+	want := fingerprint(t, key)
+
+	rejected := 0
+	for i := range shares[0].Value {
+		corrupt := *shares[0]
+		corrupt.Value = append([]byte(nil), corrupt.Value...)
+		corrupt.Value[i] ^= 0x01
+		got, err := crypt.CombineKeyShares([]*crypt.KeyShare{&corrupt, shares[1]})
+		if err != nil {
+			rejected++
+			continue
+		}
+		if fingerprint(t, got) != want {
+			t.Fatalf("corrupting byte %d of a share rebuilt another key", i)
+		}
+	}
+	if rejected == 0 {
+		t.Error("no corrupted share was rejected")
+	}
+
+	_, other := splitKey(t, crypt.AlgorithmEd25519, 3, 2)
+	_, err := crypt.CombineKeyShares([]*crypt.KeyShare{shares[0], other[1]})
+	if err == nil {
+		t.Error("shares of different keys were combined")
+	}
+}
+
+func TestParseKeySharesRejectsBadHeaders(t *testing.T) {
+	_, shares := splitKey(t, crypt.AlgorithmEd25519, 3, 2)
+	block, _ := pem.Decode(shares[1].MarshalPEM())
+
+	for _, tc := range []struct {
+		header string
+		value  string
+	}{
+		{"Threshold", "0"},
+		{"Threshold", "-1"},
+		{"Threshold", "1"},
+		{"Threshold", "4"},
+		{"Shares", "1"},
+		{"Shares", "256"},
+		{"Share", "0"},
+		{"Share", "-1"},
+		{"Share", "x"},
+	} {
+		b := *block
+		b.Headers = make(map[string]string)
+		for k, v := range block.Headers {
+			b.Headers[k] = v
+		}
+		b.Headers[tc.header] = tc.value
+
+		_, err := crypt.ParseKeyShares(pem.EncodeToMemory(&b))
+		if err == nil {
+			t.Errorf("%s: %s was accepted", tc.header, tc.value)
+		}
+	}
+
+	// the index is repeated in the share itself
+	b := *block
+	b.Bytes = append([]byte{3}, block.Bytes[1:]...)
+	_, err := crypt.ParseKeyShares(pem.EncodeToMemory(&b))
+	if err == nil {
+		t.Error("share with a mismatched index was accepted")
+	}
+}
+
+func TestCombineKeySharesRejectsBadThreshold(t *testing.T) {
+	_, shares := splitKey(t, crypt.AlgorithmEd25519, 3, 2)
+
+	for _, threshold := range []int{0, -1, 1} {
+		forged := make([]*crypt.KeyShare, len(shares))
+		for i, s := range shares {
+			c := *s
+			c.Threshold = threshold
+			forged[i] = &c
+		}
+		_, err := crypt.CombineKeyShares(forged)
+		if err == nil {
+			t.Errorf("threshold %d was accepted", threshold)
+		}
+	}
+}
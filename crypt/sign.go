@@ -0,0 +1,95 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+)
+
+// Signature algorithms, picked from the type of the signing key
+const (
+	SignEd25519 = "ed25519"
+	SignRSAPSS  = "rsa-pss-sha256"
+	SignECDSA   = "ecdsa-sha256"
+)
+
+// Sign signs msg with key and returns the algorithm used. Ed25519 keys
+// sign msg itself; RSA keys use PSS and ECDSA keys ASN.1 signatures over
+// its SHA-256 digest.
+func Sign(key crypto.PrivateKey, msg []byte) (string, []byte, error) {
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return SignEd25519, ed25519.Sign(k, msg), nil
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256(msg)
+		sig, err := rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest[:], nil)
+		return SignRSAPSS, sig, err
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(msg)
+		sig, err := ecdsa.SignASN1(rand.Reader, k, digest[:])
+		return SignECDSA, sig, err
+	}
+	return "", nil, fmt.Errorf("cannot sign with a %T key", key)
+}
+
+// VerifySignature checks a signature over msg made with algorithm by the
+// private half of pub. It returns ErrBadSignature if it does not match.
+func VerifySignature(pub crypto.PublicKey, algorithm string, msg []byte, sig []byte) error {
+	ok := false
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		ok = algorithm == SignEd25519 && ed25519.Verify(k, msg, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		ok = algorithm == SignRSAPSS && rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig, nil) == nil
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		ok = algorithm == SignECDSA && ecdsa.VerifyASN1(k, digest[:], sig)
+	default:
+		return fmt.Errorf("cannot verify with a %T key", pub)
+	}
+	if !ok {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// PublicKeyOf returns the public half of a private key
+func PublicKeyOf(key crypto.PrivateKey) (crypto.PublicKey, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("no public key for a %T key", key)
+	}
+	return signer.Public(), nil
+}
+
+// KeyFingerprint returns the hex encoded SHA-256 digest of the PKIX
+// encoding of pub, identifying the key in signatures
+func KeyFingerprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
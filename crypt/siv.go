@@ -0,0 +1,156 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+)
+
+// AES-SIV (RFC 5297) deterministic authenticated encryption. The key is
+// split in half: the first half keys S2V, built on AES-CMAC, and the
+// second half keys AES-CTR. The synthetic IV is prepended to the
+// ciphertext.
+
+const sivBlockSize = aes.BlockSize
+
+// sivSeal encrypts plaintext, authenticating the associated data ad
+func sivSeal(key []byte, plaintext []byte, ad ...[]byte) ([]byte, error) {
+	if len(key) != 32 && len(key) != 48 && len(key) != 64 {
+		return nil, errors.New("invalid AES-SIV key size")
+	}
+	mac, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+
+	v := s2v(mac, plaintext, ad)
+	out := make([]byte, sivBlockSize+len(plaintext))
+	copy(out, v)
+	sivCTR(ctr, v, out[sivBlockSize:], plaintext)
+	return out, nil
+}
+
+// sivOpen decrypts and authenticates a value sealed by sivSeal
+func sivOpen(key []byte, sealed []byte, ad ...[]byte) ([]byte, error) {
+	if len(key) != 32 && len(key) != 48 && len(key) != 64 {
+		return nil, errors.New("invalid AES-SIV key size")
+	}
+	if len(sealed) < sivBlockSize {
+		return nil, errors.New("AES-SIV ciphertext too short")
+	}
+	mac, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	ctr, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+
+	v := sealed[:sivBlockSize]
+	plaintext := make([]byte, len(sealed)-sivBlockSize)
+	sivCTR(ctr, v, plaintext, sealed[sivBlockSize:])
+	if subtle.ConstantTimeCompare(v, s2v(mac, plaintext, ad)) != 1 {
+		return nil, errors.New("AES-SIV authentication failed")
+	}
+	return plaintext, nil
+}
+
+// sivCTR runs AES-CTR from the synthetic IV with bits 31 and 63 cleared
+func sivCTR(block cipher.Block, v []byte, dst, src []byte) {
+	iv := make([]byte, sivBlockSize)
+	copy(iv, v)
+	iv[8] &= 0x7f
+	iv[12] &= 0x7f
+	cipher.NewCTR(block, iv).XORKeyStream(dst, src)
+}
+
+// s2v derives the synthetic IV from the associated data and plaintext
+func s2v(block cipher.Block, plaintext []byte, ad [][]byte) []byte {
+	d := cmac(block, make([]byte, sivBlockSize))
+	for _, a := range ad {
+		dbl(d)
+		xorBytes(d, cmac(block, a))
+	}
+
+	var t []byte
+	if len(plaintext) >= sivBlockSize {
+		t = append([]byte{}, plaintext...)
+		xorBytes(t[len(t)-sivBlockSize:], d)
+	} else {
+		dbl(d)
+		t = make([]byte, sivBlockSize)
+		copy(t, plaintext)
+		t[len(plaintext)] = 0x80
+		xorBytes(t, d)
+	}
+	return cmac(block, t)
+}
+
+// cmac computes AES-CMAC (RFC 4493) of msg
+func cmac(block cipher.Block, msg []byte) []byte {
+	k1 := make([]byte, sivBlockSize)
+	block.Encrypt(k1, k1)
+	dbl(k1)
+	k2 := append([]byte{}, k1...)
+	dbl(k2)
+
+	n := (len(msg) + sivBlockSize - 1) / sivBlockSize
+	last := make([]byte, sivBlockSize)
+	if n > 0 && len(msg)%sivBlockSize == 0 {
+		copy(last, msg[(n-1)*sivBlockSize:])
+		xorBytes(last, k1)
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		rest := msg[(n-1)*sivBlockSize:]
+		copy(last, rest)
+		last[len(rest)] = 0x80
+		xorBytes(last, k2)
+	}
+
+	x := make([]byte, sivBlockSize)
+	for i := 0; i < n-1; i++ {
+		xorBytes(x, msg[i*sivBlockSize:(i+1)*sivBlockSize])
+		block.Encrypt(x, x)
+	}
+	xorBytes(x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+// dbl multiplies a block by x in GF(2^128), in place
+func dbl(b []byte) {
+	carry := b[0] >> 7
+	for i := 0; i < len(b)-1; i++ {
+		b[i] = b[i]<<1 | b[i+1]>>7
+	}
+	b[len(b)-1] = b[len(b)-1]<<1 ^ 0x87*carry
+}
+
+// xorBytes sets dst to dst XOR src over the length of src
+func xorBytes(dst, src []byte) {
+	for i := range src {
+		dst[i] ^= src[i]
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// unhex decodes hex written in groups, as in the RFC
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Replace(s, " ", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// sivVectors are the test vectors of RFC 5297 appendix A. The nonce of
+// A.2 is the last associated data.
+var sivVectors = []struct {
+	name      string
+	key       string
+	ad        []string
+	plaintext string
+	sealed    string
+}{
+	{
+		name:      "A.1 deterministic",
+		key:       "fffefdfc fbfaf9f8 f7f6f5f4 f3f2f1f0 f0f1f2f3 f4f5f6f7 f8f9fafb fcfdfeff",
+		ad:        []string{"10111213 14151617 18191a1b 1c1d1e1f 20212223 24252627"},
+		plaintext: "11223344 55667788 99aabbcc ddee",
+		sealed:    "85632d07 c6e8f37f 950acd32 0a2ecc93 40c02b96 90c4dc04 daef7f6a fe5c",
+	},
+	{
+		name: "A.2 nonce based",
+		key:  "7f7e7d7c 7b7a7978 77767574 73727170 40414243 44454647 48494a4b 4c4d4e4f",
+		ad: []string{
+			"00112233 44556677 8899aabb ccddeeff deaddada deaddada ffeeddcc bbaa9988 77665544 33221100",
+			"10203040 50607080 90a0",
+			"09f91102 9d74e35b d84156c5 635688c0",
+		},
+		plaintext: "74686973 20697320 736f6d65 20706c61 696e7465 78742074 6f20656e 63727970 74207573 696e6720 5349562d 414553",
+		sealed: "7bdb6e3b 432667eb 06f4d14b ff2fbd0f cb900f2f ddbe4043 26601965 c889bf17 dba77ceb 094fa663 b7a3f748 " +
+			"ba8af829 ea64ad54 4a272e9c 485b62a3 fd5c0d",
+	},
+}
+
+func TestSIVVectors(t *testing.T) {
+	for _, v := range sivVectors {
+		t.Run(v.name, func(t *testing.T) {
+			key := unhex(t, v.key)
+			var ad [][]byte
+			for _, s := range v.ad {
+				ad = append(ad, unhex(t, s))
+			}
+			plaintext := unhex(t, v.plaintext)
+			want := unhex(t, v.sealed)
+
+			sealed, err := sivSeal(key, plaintext, ad...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(sealed, want) {
+				t.Fatalf("sealed %x, want %x", sealed, want)
+			}
+
+			opened, err := sivOpen(key, sealed, ad...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(opened, plaintext) {
+				t.Errorf("opened %x, want %x", opened, plaintext)
+			}
+		})
+	}
+}
+
+func TestSIVRejectsTampering(t *testing.T) {
+	v := sivVectors[0]
+	key := unhex(t, v.key)
+	ad := unhex(t, v.ad[0])
+	sealed := unhex(t, v.sealed)
+
+	for i := range sealed {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0x01
+		_, err := sivOpen(key, tampered, ad)
+		if err == nil {
+			t.Errorf("flipping a bit of byte %d was not detected", i)
+		}
+	}
+
+	_, err := sivOpen(key, sealed, append([]byte{0}, ad...))
+	if err == nil {
+		t.Error("changed associated data was not detected")
+	}
+	_, err = sivOpen(key, sealed)
+	if err == nil {
+		t.Error("missing associated data was not detected")
+	}
+	_, err = sivOpen(key, sealed[:sivBlockSize-1], ad)
+	if err == nil {
+		t.Error("truncated value was not rejected")
+	}
+}
+
+func TestSIVEmptyPlaintext(t *testing.T) {
+	key := unhex(t, sivVectors[0].key)
+	sealed, err := sivSeal(key, nil, []byte("label"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sealed) != sivBlockSize {
+		t.Fatalf("sealed %d bytes, want %d", len(sealed), sivBlockSize)
+	}
+	opened, err := sivOpen(key, sealed, []byte("label"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(opened) != 0 {
+		t.Errorf("opened %x, want nothing", opened)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// pemOpenSSHPrivateKey is the PEM type of keys written by ssh-keygen
+const pemOpenSSHPrivateKey = "OPENSSH PRIVATE KEY"
+
+// IsOpenSSHKey reports whether b holds a private key in the OpenSSH format
+func IsOpenSSHKey(b []byte) bool {
+	block, _ := pem.Decode(b)
+	return block != nil && block.Type == pemOpenSSHPrivateKey
+}
+
+// parseAuthorizedKey parses the first key of an OpenSSH authorized_keys
+// file, such as ~/.ssh/id_ed25519.pub
+func parseAuthorizedKey(b []byte) (crypto.PublicKey, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey(b)
+	if err != nil {
+		return nil, err
+	}
+	cpk, ok := pub.(ssh.CryptoPublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported ssh key type %s", pub.Type())
+	}
+	return cpk.CryptoPublicKey(), nil
+}
+
+// parseOpenSSHPrivateKey parses an OpenSSH private key, decrypting it
+// with passphrase if it is protected
+func parseOpenSSHPrivateKey(b []byte, passphrase []byte) (crypto.PrivateKey, error) {
+	var key interface{}
+	var err error
+	if passphrase == nil {
+		key, err = ssh.ParseRawPrivateKey(b)
+	} else {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(b, passphrase)
+	}
+
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missing):
+		return nil, ErrPassphraseRequired
+	case errors.Is(err, x509.IncorrectPasswordError):
+		return nil, ErrIncorrectPassphrase
+	case err != nil:
+		return nil, err
+	}
+
+	// ssh returns Ed25519 keys by pointer, everything else uses the value
+	if k, ok := key.(*ed25519.PrivateKey); ok {
+		return *k, nil
+	}
+	return key, nil
+}
+
+// curve25519P is the prime 2^255 - 19
+var curve25519P, _ = new(big.Int).SetString("57896044618658097711785492504343953926634992332820282019728792003956564819949", 10)
+
+// ed25519PublicKeyToECDH converts an Ed25519 public key to the X25519 key
+// of the same secret, using the birational map u = (1 + y) / (1 - y)
+func ed25519PublicKeyToECDH(pub ed25519.PublicKey) (*ecdh.PublicKey, error) {
+	if len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	// the key is y in little endian with the sign of x in the top bit
+	le := make([]byte, len(pub))
+	for i := range pub {
+		le[len(pub)-1-i] = pub[i]
+	}
+	le[0] &= 0x7f
+	y := new(big.Int).SetBytes(le)
+
+	num := new(big.Int).Add(big.NewInt(1), y)
+	den := new(big.Int).Sub(big.NewInt(1), y)
+	den.Mod(den, curve25519P)
+	if den.ModInverse(den, curve25519P) == nil {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+	u := num.Mul(num, den).Mod(num, curve25519P).FillBytes(make([]byte, 32))
+	for i, j := 0, len(u)-1; i < j; i, j = i+1, j-1 {
+		u[i], u[j] = u[j], u[i]
+	}
+	return ecdh.X25519().NewPublicKey(u)
+}
+
+// ed25519PrivateKeyToECDH converts an Ed25519 private key to the X25519
+// key derived from the same seed
+func ed25519PrivateKeyToECDH(priv ed25519.PrivateKey) (*ecdh.PrivateKey, error) {
+	h := sha512.Sum512(priv.Seed())
+	return ecdh.X25519().NewPrivateKey(h[:32])
+}
@@ -0,0 +1,136 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+// The keys in testdata were made with ssh-keygen; id_ed25519_protected
+// has the passphrase "passphrase".
+
+// fixtureKey returns a provider for the private key in testdata. Reading
+// through a ReaderKey leaves out the permission check on the checked out
+// file.
+func fixtureKey(t *testing.T, name string) crypt.KeyProvider {
+	t.Helper()
+	return &crypt.ReaderKey{Name: name, Reader: bytes.NewReader(readFixture(t, name))}
+}
+
+func TestParseAuthorizedKeys(t *testing.T) {
+	for _, tc := range []struct {
+		file  string
+		check func(interface{}) bool
+	}{
+		{"id_ed25519.pub", func(k interface{}) bool { _, ok := k.(ed25519.PublicKey); return ok }},
+		{"id_rsa.pub", func(k interface{}) bool { _, ok := k.(*rsa.PublicKey); return ok }},
+	} {
+		pub, err := crypt.LoadPublicKey(filepath.Join("testdata", tc.file))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.file, err)
+		}
+		if !tc.check(pub) {
+			t.Errorf("%s: unexpected key type %T", tc.file, pub)
+		}
+	}
+
+	_, err := crypt.ParsePublicKey([]byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5 truncated\n"))
+	if err == nil {
+		t.Error("truncated authorized key was accepted")
+	}
+}
+
+func TestOpenSSHKeysRoundTrip(t *testing.T) {
+	for _, name := range []string{"id_ed25519", "id_rsa"} {
+		t.Run(name, func(t *testing.T) {
+			e, err := crypt.LoadEncrypter(filepath.Join("testdata", name+".pub"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			s, err := crypt.EncryptWith(e, []byte("hunter2"), "label")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			d := crypt.NewDecrypter(fixtureKey(t, name))
+			b, err := crypt.DecryptWith(d, s, "label")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != "hunter2" {
+				t.Errorf("decrypted %q", b)
+			}
+		})
+	}
+}
+
+func TestOpenSSHKeyPassphrase(t *testing.T) {
+	if !crypt.IsOpenSSHKey(readFixture(t, "id_ed25519_protected")) {
+		t.Fatal("fixture is not recognized as an OpenSSH key")
+	}
+
+	key := crypt.NewDecrypter(fixtureKey(t, "id_ed25519_protected"), crypt.WithPassphrase(func(string) ([]byte, error) {
+		return []byte("passphrase"), nil
+	}))
+	_, err := key.PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := crypt.NewDecrypter(fixtureKey(t, "id_ed25519_protected"), crypt.WithPassphrase(func(string) ([]byte, error) {
+		return []byte("wrong"), nil
+	}))
+	_, err = wrong.PrivateKey()
+	if !errors.Is(err, crypt.ErrIncorrectPassphrase) {
+		t.Errorf("want ErrIncorrectPassphrase, got %v", err)
+	}
+}
+
+// The public key derived from an OpenSSH Ed25519 private key must match
+// the authorized key, as values are encrypted to its X25519 form
+func TestOpenSSHKeyMatchesAuthorizedKey(t *testing.T) {
+	key, err := crypt.NewDecrypter(fixtureKey(t, "id_ed25519")).PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := crypt.LoadPublicKey(filepath.Join("testdata", "id_ed25519.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := crypt.KeyFingerprint(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fingerprint(t, key); got != want {
+		t.Errorf("private key fingerprint %s, want %s", got, want)
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
@@ -0,0 +1,153 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTransitMount is where Vault mounts the transit engine by default
+const DefaultTransitMount = "transit"
+
+// VaultTransit is a KMS backed by the HashiCorp Vault transit secrets engine
+type VaultTransit struct {
+	Address string
+	Token   string
+	Mount   string
+	Key     string
+	Client  *http.Client
+}
+
+// NewVaultTransit returns a transit client for key on the Vault at address
+func NewVaultTransit(address string, token string, key string) *VaultTransit {
+	return &VaultTransit{
+		Address: address,
+		Token:   token,
+		Mount:   DefaultTransitMount,
+		Key:     key,
+		Client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Name returns "vault"
+func (v *VaultTransit) Name() string {
+	return "vault"
+}
+
+// KeyID returns the name of the transit key
+func (v *VaultTransit) KeyID() string {
+	return v.Key
+}
+
+// WrapKey encrypts dataKey with the transit key
+func (v *VaultTransit) WrapKey(dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	err := v.call("encrypt", v.Key, map[string]string{
+		"plaintext": base64.StdEncoding.EncodeToString(dataKey),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(resp.Ciphertext), nil
+}
+
+// UnwrapKey decrypts a data key wrapped by the transit key named keyID.
+// keyID comes from the envelope, so only the configured key is accepted;
+// otherwise a crafted file could make smithy post to any Vault path with
+// the user's token.
+func (v *VaultTransit) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID == "" {
+		keyID = v.Key
+	}
+	if keyID != v.Key {
+		return nil, fmt.Errorf("%w: vault: value was wrapped by transit key %q, not the configured key %q", ErrKeyNotFound, keyID, v.Key)
+	}
+
+	var resp struct {
+		Plaintext string `json:"plaintext"`
+	}
+	err := v.call("decrypt", keyID, map[string]string{
+		"ciphertext": string(wrapped),
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
+
+// call posts req to the transit endpoint op for key and decodes the data
+// field of the response into resp
+func (v *VaultTransit) call(op string, key string, req interface{}, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	mount := v.Mount
+	if mount == "" {
+		mount = DefaultTransitMount
+	}
+	segments := strings.Split(strings.Trim(mount, "/"), "/")
+	segments = append(segments, op, key)
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+	endpoint := strings.TrimRight(v.Address, "/") + "/v1/" + strings.Join(segments, "/")
+
+	r, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Vault-Token", v.Token)
+
+	client := v.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(r)
+	if err != nil {
+		return fmt.Errorf("vault: %v", err)
+	}
+	defer res.Body.Close()
+
+	var out struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&out)
+	if err != nil {
+		return fmt.Errorf("vault: %s: invalid response: %v", res.Status, err)
+	}
+
+	switch {
+	case res.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: vault: permission denied for key %s", ErrKeyNotFound, key)
+	case res.StatusCode == http.StatusBadRequest && op == "decrypt":
+		return fmt.Errorf("%w: vault: %s", ErrWrongKey, strings.Join(out.Errors, "; "))
+	case res.StatusCode != http.StatusOK:
+		return fmt.Errorf("vault: %s: %s", res.Status, strings.Join(out.Errors, "; "))
+	}
+	return json.Unmarshal(out.Data, resp)
+}
@@ -0,0 +1,100 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MetadataKey is the top level key of the block holding smithy metadata,
+// such as the document MAC. It is left out of the canonical form.
+const MetadataKey = "smithy"
+
+// metadataFields are the only fields a smithy metadata block holds
+var metadataFields = map[string]bool{
+	macField:       true,
+	macKeyField:    true,
+	signatureField: true,
+}
+
+// Canonical returns a stable encoding of the object, independent of the
+// order of its keys: compact JSON with sorted keys and no HTML escaping.
+// The smithy metadata block is left out.
+func (object Object) Canonical() ([]byte, error) {
+	skip := object.Metadata() != nil
+	content := make(map[string]interface{}, len(object))
+	for k, v := range object {
+		if k != MetadataKey || !skip {
+			content[k] = v
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(content)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Metadata returns the smithy metadata block, or nil if there is none.
+// A top level smithy key only holds the block if it is an object with
+// nothing but smithy fields; anything else is user data and is decrypted
+// and kept like any other value.
+func (object Object) Metadata() map[string]interface{} {
+	var m map[string]interface{}
+	switch v := object[MetadataKey].(type) {
+	case map[string]interface{}:
+		m = v
+	case Object:
+		m = v
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	for k := range m {
+		if !metadataFields[k] {
+			return nil
+		}
+	}
+	return m
+}
+
+// setMetadata stores a field of the smithy metadata block, creating it
+// if needed. It fails rather than overwrite user data under the key.
+func (object Object) setMetadata(key string, value interface{}) error {
+	m := object.Metadata()
+	if m == nil {
+		if _, ok := object[MetadataKey]; ok {
+			return fmt.Errorf("top level key %q holds data, it cannot hold the smithy metadata block", MetadataKey)
+		}
+		m = make(map[string]interface{})
+		object[MetadataKey] = m
+	}
+	m[key] = value
+	return nil
+}
+
+// RemoveMetadata drops the smithy metadata block, as done once a document
+// is decrypted. User data under the key is kept.
+func (object Object) RemoveMetadata() {
+	if object.Metadata() != nil {
+		delete(object, MetadataKey)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// dotenvLine matches a single KEY=value assignment, optionally preceded by export
+var dotenvLine = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_.]*)=(.*)$`)
+
+// DotenvProcessor handles transforming Objects into dotenv files and vice-versa.
+// Dotenv files are flat, so nested values are written using their string form.
+type DotenvProcessor struct{}
+
+func init() {
+	Register(FormatDotenv, []string{".env"}, NewDotenvProcessor)
+}
+
+// NewDotenvProcessor returns a dotenv backed processor.
+func NewDotenvProcessor() Processor {
+	return &DotenvProcessor{}
+}
+
+// Encode writes data to w as KEY=value lines sorted by key
+func (d *DotenvProcessor) Encode(w io.Writer, data Object) error {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if !dotenvLine.MatchString(k + "=") {
+			return fmt.Errorf("key %q is not a valid dotenv name", k)
+		}
+		_, err := fmt.Fprintf(w, "%s=%s\n", k, quoteDotenv(fmt.Sprint(data[k])))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode reads KEY=value lines from r into an Object. Blank lines and
+// lines starting with # are ignored.
+func (d *DotenvProcessor) Decode(r io.Reader) (Object, error) {
+	object := make(Object)
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := dotenvLine.FindStringSubmatch(line)
+		if m == nil {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+		v, err := unquoteDotenv(m[2])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		object[m[1]] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+// Marshal data into KEY=value lines sorted by key
+func (d *DotenvProcessor) Marshal(data Object) (*bytes.Buffer, error) {
+	return marshal(d, data)
+}
+
+// UnmarshalFile will read the contents of file and unmarshal the dotenv lines
+func (d *DotenvProcessor) UnmarshalFile(file string) (Object, error) {
+	return unmarshalFile(d, file)
+}
+
+// isDotenv reports whether every significant line of b is a dotenv assignment
+func isDotenv(b []byte) bool {
+	found := false
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !dotenvLine.MatchString(line) {
+			return false
+		}
+		found = true
+	}
+	return found && scanner.Err() == nil
+}
+
+func quoteDotenv(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n\"'#$\\`") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func unquoteDotenv(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("unterminated quoted value %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	}
+	// strip trailing comments from unquoted values
+	if i := strings.Index(s, " #"); i >= 0 {
+		s = strings.TrimSpace(s[:i])
+	}
+	return s, nil
+}
@@ -0,0 +1,76 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when no processor is registered for a format
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	// ErrNoMAC is returned when a document MAC is required but missing
+	ErrNoMAC = errors.New("document has no MAC")
+	// ErrMACMismatch is returned when a document was modified after its
+	// MAC was computed
+	ErrMACMismatch = errors.New("document MAC mismatch, the file was modified after its MAC was computed")
+	// ErrPublicKeyMAC is returned when the key of a document MAC is
+	// encrypted to a public key, which lets anyone forge the MAC
+	ErrPublicKeyMAC = errors.New("document MAC is forgeable")
+	// ErrNoSignature is returned when a signature is required but missing
+	ErrNoSignature = errors.New("document is not signed")
+	// ErrUntrustedSigner is returned when a document is signed by a key
+	// that is not trusted
+	ErrUntrustedSigner = errors.New("document signed by an untrusted key")
+)
+
+// FieldError records the document and field where processing a value
+// failed. Err is typically one of the crypt package errors.
+type FieldError struct {
+	File string
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	switch {
+	case e.File == "":
+		return fmt.Sprintf("%s: %v", e.Path, e.Err)
+	case e.Path == "":
+		return fmt.Sprintf("%s: %v", e.File, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.File, e.Path, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// InFile records file as the document an error came from. Field errors
+// are updated in place; any other error is wrapped in a FieldError.
+func InFile(err error, file string) error {
+	if err == nil {
+		return nil
+	}
+
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		fe.File = file
+		return err
+	}
+	return &FieldError{File: file, Err: err}
+}
@@ -0,0 +1,52 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pelletier/go-toml"
+)
+
+// Names of the built-in data formats
+const (
+	FormatJSON   = "json"
+	FormatYAML   = "yaml"
+	FormatTOML   = "toml"
+	FormatDotenv = "dotenv"
+)
+
+// Detect sniffs the content of b to determine its format. Since nearly
+// anything parses as YAML, YAML is returned when no other format matches.
+func Detect(b []byte) string {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(b, []byte("\xef\xbb\xbf")))
+
+	if bytes.HasPrefix(trimmed, []byte("{")) && json.Valid(trimmed) {
+		return FormatJSON
+	}
+
+	// TOML goes first as key=value lines with quoted or numeric values
+	// are valid in both, and reading them as dotenv would lose the types
+	if tree, err := toml.LoadBytes(trimmed); err == nil && len(tree.Keys()) > 0 {
+		return FormatTOML
+	}
+
+	if isDotenv(trimmed) {
+		return FormatDotenv
+	}
+
+	return FormatYAML
+}
@@ -0,0 +1,49 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_test
+
+import (
+	"testing"
+
+	"github.com/mshindle/smithy/data"
+)
+
+func TestDetect(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"json object", `{"a": "b"}`, data.FormatJSON},
+		{"json with bom", "\xef\xbb\xbf{\"a\": 1}\n", data.FormatJSON},
+		{"json array is yaml", `["a", "b"]`, data.FormatYAML},
+		{"toml", "a = \"b\"\n[db]\nport = 8080\n", data.FormatTOML},
+		{"toml without spaces", "a=\"b\"\n", data.FormatTOML},
+		{"toml number without spaces", "port=8080\n", data.FormatTOML},
+		{"toml table", "[db]\nhost=\"x\"\n", data.FormatTOML},
+		{"dotenv unquoted", "HOST=localhost\nPORT=8080\n", data.FormatDotenv},
+		{"dotenv export", "export TOKEN=\"x\"\n", data.FormatDotenv},
+		{"dotenv encrypted", "# secrets\nTOKEN=ENC[abc=]\n", data.FormatDotenv},
+		{"yaml", "a: b\nc:\n  - d\n", data.FormatYAML},
+		{"empty", "", data.FormatYAML},
+		{"comments only", "# nothing\n", data.FormatYAML},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := data.Detect([]byte(tc.input)); got != tc.want {
+				t.Errorf("Detect(%q) = %s, want %s", tc.input, got, tc.want)
+			}
+		})
+	}
+}
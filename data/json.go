@@ -16,41 +16,53 @@ package data
 
 import (
 	"bytes"
-
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"io"
 )
 
 // JsonProcessor handles transforming Objects into JSON and vice-versa
 type JsonProcessor struct{}
 
+func init() {
+	Register(FormatJSON, []string{".json"}, NewJsonProcessor)
+}
+
 // NewJsonProcessor returns a JSON backed processor.
 func NewJsonProcessor() Processor {
 	return &JsonProcessor{}
 }
 
-func (j *JsonProcessor) Marshal(data Object) (*bytes.Buffer, error) {
-	m, err := json.MarshalIndent(data, "", "    ")
-	if err != nil {
-		return nil, err
-	}
-
-	return bytes.NewBuffer(m), nil
+// Encode writes data to w as indented json
+func (j *JsonProcessor) Encode(w io.Writer, data Object) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(data)
 }
 
-// UnmarshalFile will read the contents of file, unmarshal the json, and convert any encrypted data fields
-func (j *JsonProcessor) UnmarshalFile(file string) (Object, error) {
+// Decode reads a json document from r into an Object. Anything but
+// whitespace after the document is an error.
+func (j *JsonProcessor) Decode(r io.Reader) (Object, error) {
 	var object Object
 
-	fileBytes, err := ioutil.ReadFile(file)
+	decoder := json.NewDecoder(r)
+	err := decoder.Decode(&object)
 	if err != nil {
 		return object, err
 	}
-
-	err = json.Unmarshal(fileBytes, &object)
-	if err != nil {
-		return object, err
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid character after top-level json value")
 	}
 
 	return object, nil
 }
+
+// Marshal data into an indented json buffer
+func (j *JsonProcessor) Marshal(data Object) (*bytes.Buffer, error) {
+	return marshal(j, data)
+}
+
+// UnmarshalFile will read the contents of file and unmarshal the json
+func (j *JsonProcessor) UnmarshalFile(file string) (Object, error) {
+	return unmarshalFile(j, file)
+}
@@ -0,0 +1,45 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mshindle/smithy/data"
+)
+
+func TestJSONDecodeTrailingData(t *testing.T) {
+	for _, tc := range []struct {
+		input string
+		ok    bool
+	}{
+		{"{}", true},
+		{"{}\n", true},
+		{" {\"a\": \"b\"} \r\n\t", true},
+		{"{}{}", false},
+		{"{} x", false},
+		{"{}\n[]", false},
+		{"{} \"a\"", false},
+	} {
+		_, err := data.NewJsonProcessor().Decode(strings.NewReader(tc.input))
+		if tc.ok && err != nil {
+			t.Errorf("%q was rejected: %v", tc.input, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("%q was accepted", tc.input)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+// Fields of the smithy metadata block holding the document MAC
+const (
+	macField    = "mac"
+	macKeyField = "macKey"
+)
+
+// macKeySize is the length of the HMAC-SHA256 key
+const macKeySize = 32
+
+// macContext is prepended to the canonical document before computing its MAC
+const macContext = "smithy document mac v1\x00"
+
+// macLabel returns the label the MAC key is encrypted with, kept apart
+// from the label of the values so neither can stand in for the other
+func macLabel(label string) string {
+	return label + "\x00smithy mac key"
+}
+
+// HasMAC reports whether the object carries a document MAC
+func (object Object) HasMAC() bool {
+	_, ok := object.Metadata()[macField]
+	return ok
+}
+
+// AddMAC computes a MAC over every value of the object, plaintext and
+// encrypted alike, with a new random key. The key is encrypted with e and
+// stored next to the MAC in the smithy metadata block.
+//
+// e must use a secret key method such as siv or vault: a MAC key
+// encrypted to a public key could be replaced, along with the MAC, by
+// anyone holding the public key, so it would not protect anything.
+func (object Object) AddMAC(e crypt.Encrypter, label string) error {
+	key := make([]byte, macKeySize)
+	_, err := io.ReadFull(rand.Reader, key)
+	if err != nil {
+		return err
+	}
+	env, err := e.Encrypt(key, macLabel(label))
+	if err != nil {
+		return err
+	}
+	if !crypt.IsSecretKeyMethod(env.Method) {
+		return fmt.Errorf("%w: anyone with the public key of the %s method could recompute it, use siv or vault", ErrPublicKeyMAC, env.Method)
+	}
+	err = object.setMetadata(macKeyField, env.String())
+	if err != nil {
+		return err
+	}
+	return object.setMAC(key)
+}
+
+// CopyMACKey stores the encrypted MAC key of other, such as the previous
+// version of the document, so that UpdateMAC reuses it. It reports
+// whether other had one.
+func (object Object) CopyMACKey(other Object) (bool, error) {
+	wrapped, ok := other.Metadata()[macKeyField].(string)
+	if !ok {
+		return false, nil
+	}
+	return true, object.setMetadata(macKeyField, wrapped)
+}
+
+// UpdateMAC recomputes the MAC of an object that already has one, reusing
+// its key decrypted with d
+func (object Object) UpdateMAC(d crypt.Decrypter, label string) error {
+	key, err := object.macKey(d, label)
+	if err != nil {
+		return err
+	}
+	return object.setMAC(key)
+}
+
+// VerifyMAC checks the document MAC with the key decrypted with d. It
+// returns ErrNoMAC if there is none, ErrPublicKeyMAC if its key is not
+// encrypted with a secret key method and ErrMACMismatch if any value was
+// changed, added or removed since it was computed.
+func (object Object) VerifyMAC(d crypt.Decrypter, label string) error {
+	s, ok := object.Metadata()[macField].(string)
+	if !ok {
+		return ErrNoMAC
+	}
+	want, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("%w: invalid mac encoding", ErrMACMismatch)
+	}
+
+	key, err := object.macKey(d, label)
+	if err != nil {
+		return err
+	}
+	got, err := object.mac(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(got, want) {
+		return ErrMACMismatch
+	}
+	return nil
+}
+
+func (object Object) macKey(d crypt.Decrypter, label string) ([]byte, error) {
+	wrapped, ok := object.Metadata()[macKeyField].(string)
+	if !ok {
+		return nil, ErrNoMAC
+	}
+	env, err := crypt.ParseEnvelope(wrapped)
+	if err != nil {
+		return nil, &FieldError{Path: MetadataKey + "." + macKeyField, Err: err}
+	}
+	if !crypt.IsSecretKeyMethod(env.Method) {
+		return nil, fmt.Errorf("%w: its key is encrypted with the %s method", ErrPublicKeyMAC, env.Method)
+	}
+	key, err := d.Decrypt(env, macLabel(label))
+	if err != nil {
+		return nil, &FieldError{Path: MetadataKey + "." + macKeyField, Err: err}
+	}
+	return key, nil
+}
+
+func (object Object) setMAC(key []byte) error {
+	sum, err := object.mac(key)
+	if err != nil {
+		return err
+	}
+	return object.setMetadata(macField, base64.StdEncoding.EncodeToString(sum))
+}
+
+func (object Object) mac(key []byte) ([]byte, error) {
+	canonical, err := object.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(macContext))
+	h.Write(canonical)
+	return h.Sum(nil), nil
+}
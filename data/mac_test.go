@@ -0,0 +1,174 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
+)
+
+// macObject returns a document with a MAC under a Vault transit key
+func macObject(t *testing.T) (data.Object, crypt.Decrypter) {
+	t.Helper()
+	e, d := newTransit(t)
+	object := data.Object{
+		"db": map[string]interface{}{
+			"host":     "localhost",
+			"password": encryptValue(t, e, "hunter2", crypt.Binding{}),
+		},
+		"hosts": []interface{}{"a", "b"},
+	}
+	err := object.AddMAC(e, "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return object, d
+}
+
+func TestMACDetectsChanges(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change func(data.Object)
+	}{
+		{"changed value", func(o data.Object) { o["db"].(map[string]interface{})["host"] = "evil" }},
+		{"added value", func(o data.Object) { o["db"].(map[string]interface{})["user"] = "root" }},
+		{"removed value", func(o data.Object) { delete(o["db"].(map[string]interface{}), "host") }},
+		{"added top level value", func(o data.Object) { o["debug"] = true }},
+		{"reordered list", func(o data.Object) { o["hosts"] = []interface{}{"b", "a"} }},
+		{"swapped ciphertext", func(o data.Object) {
+			db := o["db"].(map[string]interface{})
+			db["host"], db["password"] = db["password"], db["host"]
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			object, d := macObject(t)
+			err := object.VerifyMAC(d, "label")
+			if err != nil {
+				t.Fatalf("unchanged document: %v", err)
+			}
+
+			tc.change(object)
+			err = object.VerifyMAC(d, "label")
+			if !errors.Is(err, data.ErrMACMismatch) {
+				t.Errorf("want ErrMACMismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMACMissing(t *testing.T) {
+	_, d := newTransit(t)
+	err := data.Object{"a": "b"}.VerifyMAC(d, "label")
+	if !errors.Is(err, data.ErrNoMAC) {
+		t.Errorf("want ErrNoMAC, got %v", err)
+	}
+}
+
+func TestMACRejectsPublicKeyMethods(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv := filepath.Join(dir, "public.key"), filepath.Join(dir, "private.key")
+	err := crypt.Generate(pub, priv, crypt.AlgorithmEd25519, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := crypt.LoadEncrypter(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	object := data.Object{"a": "b"}
+	err = object.AddMAC(e, "label")
+	if !errors.Is(err, data.ErrPublicKeyMAC) {
+		t.Fatalf("AddMAC: want ErrPublicKeyMAC, got %v", err)
+	}
+	if object.HasMAC() {
+		t.Error("a MAC was added")
+	}
+
+	// a MAC key replaced by someone holding only the public key is refused
+	// before it is decrypted
+	forged, _ := macObject(t)
+	wrapped, err := crypt.EncryptWith(e, make([]byte, 32), "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged.Metadata()["macKey"] = wrapped
+	d := crypt.MethodDecrypter{
+		crypt.MethodECIES: crypt.NewDecrypter(crypt.FileKey(priv)),
+	}
+	err = forged.VerifyMAC(d, "label")
+	if !errors.Is(err, data.ErrPublicKeyMAC) {
+		t.Errorf("VerifyMAC: want ErrPublicKeyMAC, got %v", err)
+	}
+}
+
+func TestMACKeyReused(t *testing.T) {
+	previous, d := macObject(t)
+
+	// the same document encrypted again, as by encrypt --mac
+	object := data.Object{
+		"db":    previous["db"],
+		"hosts": previous["hosts"],
+	}
+	ok, err := object.CopyMACKey(previous)
+	if err != nil || !ok {
+		t.Fatalf("CopyMACKey = %v, %v", ok, err)
+	}
+	err = object.UpdateMAC(d, "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, field := range []string{"mac", "macKey"} {
+		if object.Metadata()[field] != previous.Metadata()[field] {
+			t.Errorf("%s changed from %v to %v", field, previous.Metadata()[field], object.Metadata()[field])
+		}
+	}
+	err = object.VerifyMAC(d, "label")
+	if err != nil {
+		t.Error(err)
+	}
+
+	ok, err = object.CopyMACKey(data.Object{"a": "b"})
+	if ok || err != nil {
+		t.Errorf("copying from a document without a MAC = %v, %v", ok, err)
+	}
+}
+
+func TestMetadataShape(t *testing.T) {
+	e, d := newTransit(t)
+	user := map[string]interface{}{"version": encryptValue(t, e, "3", crypt.Binding{})}
+	object := data.Object{data.MetadataKey: user}
+
+	if object.Metadata() != nil {
+		t.Fatal("user data under the smithy key was taken as metadata")
+	}
+	err := object.AddMAC(e, "label")
+	if err == nil {
+		t.Fatal("MAC overwrote user data under the smithy key")
+	}
+	err = object.DecryptValuesWith(d, data.DecryptOptions{Label: "label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	object.RemoveMetadata()
+	if got := object[data.MetadataKey].(map[string]interface{})["version"]; got != "3" {
+		t.Errorf("smithy.version = %v", got)
+	}
+}
@@ -16,48 +16,151 @@ package data
 
 import (
 	"bytes"
-	"regexp"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/mshindle/smithy/crypt"
 )
 
 // Object represent a structured data map with string keys
 type Object map[string]interface{}
 
-// Processor manages transforming objects into specific data formats
+// Processor manages transforming objects into specific data formats.
+// Decode and Encode work on streams; Marshal and UnmarshalFile are
+// conveniences built on top of them.
 type Processor interface {
+	Decode(io.Reader) (Object, error)
+	Encode(io.Writer, Object) error
 	Marshal(Object) (*bytes.Buffer, error)
 	UnmarshalFile(string) (Object, error)
 }
 
-// DecryptValue decrypts encrypted values in an object
-func (object Object) DecryptValues(label string, file string) error {
-	match, err := regexp.Compile("^ENC\\[*")
+// marshal encodes data with p into a new buffer
+func marshal(p Processor, data Object) (*bytes.Buffer, error) {
+	buffer := new(bytes.Buffer)
+	err := p.Encode(buffer, data)
 	if err != nil {
-		log.WithError(err).Error("cannot compile ENC regex pattern")
-		return err
+		return nil, err
 	}
+	return buffer, nil
+}
+
+// unmarshalFile decodes the contents of file with p
+func unmarshalFile(p Processor, file string) (Object, error) {
+	infile, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
+
+	return p.Decode(infile)
+}
+
+// DecryptOptions controls how DecryptValuesWith decrypts an object
+type DecryptOptions struct {
+	// Label is the label the values were encrypted with
+	Label string
+	// File is the identity of the file for values bound to their file. It
+	// is chosen by the caller, such as a path relative to a project root,
+	// so that files with the same name in different places differ.
+	File string
+	// RequireBinding rejects values not bound to their field path
+	RequireBinding bool
+	// AllowWhitespace accepts values with whitespace around or inside
+	// the envelope, such as base64 wrapped over several lines
+	AllowWhitespace bool
+}
 
-	return object.decrypt(match, label, file)
+// DecryptValues decrypts encrypted values in an object with d. Failures
+// are returned as a *FieldError naming the path of the value.
+func (object Object) DecryptValues(label string, d crypt.Decrypter) error {
+	return object.DecryptValuesWith(d, DecryptOptions{Label: label})
 }
 
-func (object Object) decrypt(match *regexp.Regexp, label string, file string) error {
+// DecryptValuesWith decrypts encrypted values in an object with d,
+// including those in nested objects and lists. Values bound to their
+// field path fail to decrypt if they were moved to another field. The smithy metadata block is left alone. Failures are returned as
+// a *FieldError naming the path of the value.
+func (object Object) DecryptValuesWith(d crypt.Decrypter, opts DecryptOptions) error {
+	var parse []crypt.ParseOption
+	if opts.RequireBinding {
+		parse = append(parse, crypt.RequireBinding())
+	}
+	if opts.AllowWhitespace {
+		parse = append(parse, crypt.AllowWhitespace())
+	}
+	return object.decrypt("", d, opts, parse)
+}
+
+func (object Object) decrypt(prefix string, d crypt.Decrypter, opts DecryptOptions, parse []crypt.ParseOption) error {
+	skip := prefix == "" && object.Metadata() != nil
 	for k, v := range object {
-		s, ok := v.(string)
-		if ok && match.MatchString(s) {
-			b, err := crypt.DecryptFromString(v.(string), label, file)
-			if err != nil {
-				return err
-			}
-			object[k] = string(b)
-		} else if m, ok := v.(map[string]interface{}); ok {
-			log.WithField("key", k).Debug("looping on key")
-			err := Object(m).decrypt(match, label, file)
+		if skip && k == MetadataKey {
+			continue
+		}
+		out, err := decryptValue(joinPath(prefix, k), v, d, opts, parse)
+		if err != nil {
+			return err
+		}
+		object[k] = out
+	}
+	return nil
+}
+
+// decryptValue returns v, found at path, with its encrypted strings
+// decrypted. Objects and lists are decrypted in place; elements of lists
+// have paths like hosts[0].
+func decryptValue(path string, v interface{}, d crypt.Decrypter, opts DecryptOptions, parse []crypt.ParseOption) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		ok := crypt.IsEncrypted(t)
+		if opts.AllowWhitespace {
+			ok = crypt.IsEncrypted(strings.TrimSpace(t))
+		}
+		if !ok {
+			return t, nil
+		}
+		b, err := crypt.DecryptBound(d, t, opts.Label, crypt.Binding{Path: path, File: opts.File}, parse...)
+		if err != nil {
+			return nil, &FieldError{Path: path, Err: err}
+		}
+		return string(b), nil
+	case map[string]interface{}:
+		return t, Object(t).decrypt(path, d, opts, parse)
+	case []interface{}:
+		for i, child := range t {
+			out, err := decryptValue(fmt.Sprintf("%s[%d]", path, i), child, d, opts, parse)
 			if err != nil {
-				return err
+				return nil, err
 			}
+			t[i] = out
 		}
+		return t, nil
 	}
-	return nil
+	return v, nil
+}
+
+// SetPath stores value at the dotted path, creating intermediate objects
+func (object Object) SetPath(path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	m := map[string]interface{}(object)
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+}
+
+// joinPath builds the dotted path of key below prefix
+func joinPath(prefix string, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
 }
@@ -0,0 +1,92 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/crypt/kmstest"
+	"github.com/mshindle/smithy/data"
+)
+
+// newTransit returns an Encrypter and Decrypter backed by a fake Vault
+// transit key, closed at the end of the test
+func newTransit(t *testing.T) (crypt.Encrypter, crypt.Decrypter) {
+	t.Helper()
+	server := kmstest.NewVaultServer("token")
+	t.Cleanup(server.Close)
+	transit := server.Transit("smithy")
+	return crypt.NewKMSEncrypter(transit), crypt.NewKMSDecrypter(transit)
+}
+
+func encryptValue(t *testing.T, e crypt.Encrypter, value string, binding crypt.Binding) string {
+	t.Helper()
+	var s string
+	var err error
+	if binding == (crypt.Binding{}) {
+		s, err = crypt.EncryptWith(e, []byte(value), "label")
+	} else {
+		s, err = crypt.EncryptBound(e, []byte(value), "label", binding)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDecryptValuesInLists(t *testing.T) {
+	e, d := newTransit(t)
+	object := data.Object{
+		"hosts": []interface{}{
+			"plain",
+			encryptValue(t, e, "first", crypt.Binding{}),
+			map[string]interface{}{"password": encryptValue(t, e, "nested", crypt.Binding{Path: "hosts[2].password"})},
+			[]interface{}{encryptValue(t, e, "deep", crypt.Binding{Path: "hosts[3][0]"})},
+		},
+	}
+
+	err := object.DecryptValuesWith(d, data.DecryptOptions{Label: "label"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := object["hosts"].([]interface{})
+	if hosts[0] != "plain" || hosts[1] != "first" {
+		t.Errorf("unexpected hosts %v", hosts)
+	}
+	if got := hosts[2].(map[string]interface{})["password"]; got != "nested" {
+		t.Errorf("hosts[2].password = %v", got)
+	}
+	if got := hosts[3].([]interface{})[0]; got != "deep" {
+		t.Errorf("hosts[3][0] = %v", got)
+	}
+}
+
+func TestDecryptValuesInListsReportsPath(t *testing.T) {
+	e, d := newTransit(t)
+	object := data.Object{
+		"hosts": []interface{}{"plain", encryptValue(t, e, "moved", crypt.Binding{Path: "hosts[0]"})},
+	}
+
+	err := object.DecryptValuesWith(d, data.DecryptOptions{Label: "label"})
+	var fe *data.FieldError
+	if !errors.As(err, &fe) || fe.Path != "hosts[1]" {
+		t.Fatalf("want a field error at hosts[1], got %v", err)
+	}
+	if !errors.Is(err, crypt.ErrWrongKey) {
+		t.Errorf("want ErrWrongKey, got %v", err)
+	}
+}
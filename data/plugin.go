@@ -0,0 +1,129 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// PluginProcessor delegates decoding and encoding to an external command.
+// The command is run once per operation; it receives a single JSON request
+// on stdin and must answer with a single JSON response on stdout:
+//
+//	{"op":"decode","data":"<base64 input>"}  ->  {"object":{...}}
+//	{"op":"encode","object":{...}}           ->  {"data":"<base64 output>"}
+//
+// A plugin reports failure by answering {"error":"message"}.
+type PluginProcessor struct {
+	Command string
+	Args    []string
+}
+
+type pluginRequest struct {
+	Op     string `json:"op"`
+	Data   []byte `json:"data,omitempty"`
+	Object Object `json:"object,omitempty"`
+}
+
+type pluginResponse struct {
+	Data   []byte `json:"data,omitempty"`
+	Object Object `json:"object,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewPluginProcessor returns a processor backed by an external command
+func NewPluginProcessor(command string, args ...string) Processor {
+	return &PluginProcessor{Command: command, Args: args}
+}
+
+// RegisterPlugin registers an external command as the processor for a format
+func RegisterPlugin(name string, exts []string, command string, args ...string) {
+	Register(name, exts, func() Processor {
+		return NewPluginProcessor(command, args...)
+	})
+}
+
+// Encode asks the plugin to render data and writes the result to w
+func (p *PluginProcessor) Encode(w io.Writer, data Object) error {
+	resp, err := p.call(&pluginRequest{Op: "encode", Object: data})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(resp.Data)
+	return err
+}
+
+// Decode passes the contents of r to the plugin and returns the parsed Object
+func (p *PluginProcessor) Decode(r io.Reader) (Object, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.call(&pluginRequest{Op: "decode", Data: b})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Object == nil {
+		return nil, errors.New("plugin returned no object")
+	}
+	return resp.Object, nil
+}
+
+// Marshal data using the plugin
+func (p *PluginProcessor) Marshal(data Object) (*bytes.Buffer, error) {
+	return marshal(p, data)
+}
+
+// UnmarshalFile will read the contents of file and unmarshal it using the plugin
+func (p *PluginProcessor) UnmarshalFile(file string) (Object, error) {
+	return unmarshalFile(p, file)
+}
+
+// call runs the plugin command with req on stdin and decodes its response
+func (p *PluginProcessor) call(req *pluginRequest) (*pluginResponse, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	c := exec.Command(p.Command, p.Args...)
+	c.Stdin = bytes.NewReader(in)
+	c.Stdout = &out
+	c.Stderr = os.Stderr
+	err = c.Run()
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %v", p.Command, err)
+	}
+
+	var resp pluginResponse
+	err = json.Unmarshal(out.Bytes(), &resp)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: invalid response: %v", p.Command, err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("plugin %s: %s", p.Command, resp.Error)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,90 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Factory creates a new Processor for a registered format
+type Factory func() Processor
+
+type format struct {
+	name       string
+	extensions []string
+	factory    Factory
+}
+
+var (
+	registryMu sync.RWMutex
+	formats    = make(map[string]*format)
+	extensions = make(map[string]string)
+)
+
+// Register makes a processor factory available under name and for each of
+// the given file extensions (including the leading dot). Registering an
+// existing name or extension replaces the previous registration.
+func Register(name string, exts []string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name = strings.ToLower(name)
+	formats[name] = &format{name: name, extensions: exts, factory: factory}
+	for _, ext := range exts {
+		extensions[strings.ToLower(ext)] = name
+	}
+}
+
+// NewProcessor returns a processor for the named format. Names which are
+// not registered are tried as a file extension, so "yml" resolves to yaml.
+func NewProcessor(name string) (Processor, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	name = strings.ToLower(name)
+	f, ok := formats[name]
+	if !ok {
+		f, ok = formats[extensions["."+name]]
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+	}
+	return f.factory(), nil
+}
+
+// FormatFromExt returns the format registered for a file extension,
+// or an empty string if the extension is not recognized.
+func FormatFromExt(ext string) string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return extensions[strings.ToLower(ext)]
+}
+
+// Formats returns the sorted names of all registered formats
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,176 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+// signatureField is the field of the smithy metadata block holding an
+// inline signature
+const signatureField = "signature"
+
+// SignatureSuffix is appended to a file name to name its sidecar signature
+const SignatureSuffix = ".sig"
+
+// signatureContext is prepended to the canonical document before signing
+const signatureContext = "smithy document signature v1\x00"
+
+// Signature is a signature over the canonical form of an object. Signer
+// is the fingerprint of the public key, as returned by crypt.KeyFingerprint.
+type Signature struct {
+	Signer    string `json:"signer"`
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// Signer is a public key trusted to sign documents
+type Signer struct {
+	Name      string
+	PublicKey crypto.PublicKey
+}
+
+// Sign signs the canonical form of the object, which leaves out the smithy
+// metadata block, with key
+func (object Object) Sign(key crypto.PrivateKey) (*Signature, error) {
+	pub, err := crypt.PublicKeyOf(key)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint, err := crypt.KeyFingerprint(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := object.signedContent()
+	if err != nil {
+		return nil, err
+	}
+	algorithm, sig, err := crypt.Sign(key, msg)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{
+		Signer:    fingerprint,
+		Algorithm: algorithm,
+		Value:     base64.StdEncoding.EncodeToString(sig),
+	}, nil
+}
+
+// SetSignature stores sig inline in the smithy metadata block
+func (object Object) SetSignature(sig *Signature) error {
+	return object.setMetadata(signatureField, map[string]interface{}{
+		"signer":    sig.Signer,
+		"algorithm": sig.Algorithm,
+		"value":     sig.Value,
+	})
+}
+
+// InlineSignature returns the signature stored in the smithy metadata
+// block, or nil if there is none
+func (object Object) InlineSignature() *Signature {
+	m, ok := object.Metadata()[signatureField].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	sig := &Signature{}
+	sig.Signer, _ = m["signer"].(string)
+	sig.Algorithm, _ = m["algorithm"].(string)
+	sig.Value, _ = m["value"].(string)
+	return sig
+}
+
+// Verify checks sig against the object and returns the trusted signer
+// who made it. It fails with ErrUntrustedSigner if the key is not one of
+// signers, or there are no signers, and crypt.ErrBadSignature if the
+// object was changed.
+func (object Object) Verify(sig *Signature, signers []Signer) (*Signer, error) {
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("%w: no trusted signers", ErrUntrustedSigner)
+	}
+
+	var signer *Signer
+	for i := range signers {
+		fingerprint, err := crypt.KeyFingerprint(signers[i].PublicKey)
+		if err == nil && fingerprint == sig.Signer {
+			signer = &signers[i]
+			break
+		}
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUntrustedSigner, sig.Signer)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid signature encoding", crypt.ErrBadSignature)
+	}
+	msg, err := object.signedContent()
+	if err != nil {
+		return nil, err
+	}
+	err = crypt.VerifySignature(signer.PublicKey, sig.Algorithm, msg, value)
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// VerifyFile checks the signature of an object read from file, stored
+// inline or in the sidecar file next to it. It returns ErrNoSignature if
+// there is neither.
+func (object Object) VerifyFile(file string, signers []Signer) (*Signer, error) {
+	sig := object.InlineSignature()
+	if sig == nil {
+		var err error
+		sig, err = ReadSignature(file + SignatureSuffix)
+		if os.IsNotExist(err) {
+			return nil, ErrNoSignature
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return object.Verify(sig, signers)
+}
+
+// ReadSignature reads a sidecar signature file
+func ReadSignature(file string) (*Signature, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var sig Signature
+	err = json.Unmarshal(b, &sig)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+	return &sig, nil
+}
+
+func (object Object) signedContent() ([]byte, error) {
+	canonical, err := object.Canonical()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(signatureContext), canonical...), nil
+}
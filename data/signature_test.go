@@ -0,0 +1,152 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data_test
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
+)
+
+func signingKey(t *testing.T, algorithm string) (crypto.Signer, data.Signer) {
+	t.Helper()
+	key, err := crypt.GenerateKey(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, data.Signer{Name: algorithm, PublicKey: key.Public()}
+}
+
+func signedObject() data.Object {
+	return data.Object{
+		"db":    map[string]interface{}{"host": "localhost", "password": "ENC[abc=]"},
+		"hosts": []interface{}{"a", "b"},
+	}
+}
+
+func TestSignatureRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		algorithm string
+		want      string
+	}{
+		{crypt.AlgorithmEd25519, crypt.SignEd25519},
+		{crypt.AlgorithmRSA, crypt.SignRSAPSS},
+		{crypt.AlgorithmP256, crypt.SignECDSA},
+		{crypt.AlgorithmP384, crypt.SignECDSA},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			key, signer := signingKey(t, tc.algorithm)
+			object := signedObject()
+			sig, err := object.Sign(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sig.Algorithm != tc.want {
+				t.Errorf("algorithm %s, want %s", sig.Algorithm, tc.want)
+			}
+
+			// stored inline, the signature does not cover itself
+			err = object.SetSignature(sig)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := object.Verify(object.InlineSignature(), []data.Signer{signer})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Name != signer.Name {
+				t.Errorf("signed by %s, want %s", got.Name, signer.Name)
+			}
+		})
+	}
+}
+
+func TestSignatureTampered(t *testing.T) {
+	key, signer := signingKey(t, crypt.AlgorithmEd25519)
+	sig, err := signedObject().Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []func(data.Object){
+		func(o data.Object) { o["db"].(map[string]interface{})["host"] = "evil" },
+		func(o data.Object) { o["debug"] = true },
+		func(o data.Object) { delete(o, "hosts") },
+	} {
+		object := signedObject()
+		change(object)
+		_, err = object.Verify(sig, []data.Signer{signer})
+		if !errors.Is(err, crypt.ErrBadSignature) {
+			t.Errorf("want ErrBadSignature, got %v", err)
+		}
+	}
+
+	bad := *sig
+	bad.Value = "not base64"
+	_, err = signedObject().Verify(&bad, []data.Signer{signer})
+	if !errors.Is(err, crypt.ErrBadSignature) {
+		t.Errorf("bad encoding: want ErrBadSignature, got %v", err)
+	}
+}
+
+func TestSignatureUntrustedSigner(t *testing.T) {
+	key, _ := signingKey(t, crypt.AlgorithmEd25519)
+	_, other := signingKey(t, crypt.AlgorithmEd25519)
+	sig, err := signedObject().Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, signers := range [][]data.Signer{{other}, {}, nil} {
+		_, err = signedObject().Verify(sig, signers)
+		if !errors.Is(err, data.ErrUntrustedSigner) {
+			t.Errorf("%d signers: want ErrUntrustedSigner, got %v", len(signers), err)
+		}
+	}
+}
+
+func TestVerifyFileSidecar(t *testing.T) {
+	key, signer := signingKey(t, crypt.AlgorithmP256)
+	object := signedObject()
+	file := filepath.Join(t.TempDir(), "app.yaml")
+
+	_, err := object.VerifyFile(file, []data.Signer{signer})
+	if !errors.Is(err, data.ErrNoSignature) {
+		t.Fatalf("want ErrNoSignature, got %v", err)
+	}
+
+	sig, err := object.Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(file+data.SignatureSuffix, b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = object.VerifyFile(file, []data.Signer{signer})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package data
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/pelletier/go-toml"
+)
+
+// TomlProcessor handles transforming Objects into TOML and vice-versa
+type TomlProcessor struct{}
+
+func init() {
+	Register(FormatTOML, []string{".toml"}, NewTomlProcessor)
+}
+
+// NewTomlProcessor returns a TOML backed processor.
+func NewTomlProcessor() Processor {
+	return &TomlProcessor{}
+}
+
+// Encode writes data to w as a toml document
+func (t *TomlProcessor) Encode(w io.Writer, data Object) error {
+	tree, err := toml.TreeFromMap(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = tree.WriteTo(w)
+	return err
+}
+
+// Decode reads a toml document from r into an Object
+func (t *TomlProcessor) Decode(r io.Reader) (Object, error) {
+	tree, err := toml.LoadReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Object(tree.ToMap()), nil
+}
+
+// Marshal data into a toml document
+func (t *TomlProcessor) Marshal(data Object) (*bytes.Buffer, error) {
+	return marshal(t, data)
+}
+
+// UnmarshalFile will read the contents of file and unmarshal the toml
+func (t *TomlProcessor) UnmarshalFile(file string) (Object, error) {
+	return unmarshalFile(t, file)
+}
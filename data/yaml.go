@@ -16,6 +16,7 @@ package data
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 
 	"github.com/ghodss/yaml"
@@ -23,33 +24,49 @@ import (
 
 type YamlProcessor struct{}
 
+func init() {
+	Register(FormatYAML, []string{".yaml", ".yml"}, NewYamlProcessor)
+}
+
 // NewYamlProcessor returns an instance of a YamlProcessor
 func NewYamlProcessor() Processor {
 	return &YamlProcessor{}
 }
 
-// Marshal data string into a yaml file
-func (y *YamlProcessor) Marshal(data Object) (*bytes.Buffer, error) {
+// Encode writes data to w as a yaml document
+func (y *YamlProcessor) Encode(w io.Writer, data Object) error {
 	m, err := yaml.Marshal(data)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return bytes.NewBuffer(m), nil
+	_, err = w.Write(m)
+	return err
 }
 
-func (y *YamlProcessor) UnmarshalFile(file string) (Object, error) {
+// Decode reads a yaml document from r into an Object
+func (y *YamlProcessor) Decode(r io.Reader) (Object, error) {
 	var object Object
 
-	fileBytes, err := ioutil.ReadFile(file)
+	b, err := ioutil.ReadAll(r)
 	if err != nil {
 		return object, err
 	}
 
-	err = yaml.Unmarshal(fileBytes, &object)
+	err = yaml.Unmarshal(b, &object)
 	if err != nil {
 		return object, err
 	}
 
 	return object, nil
 }
+
+// Marshal data string into a yaml file
+func (y *YamlProcessor) Marshal(data Object) (*bytes.Buffer, error) {
+	return marshal(y, data)
+}
+
+// UnmarshalFile will read the contents of file and unmarshal the yaml
+func (y *YamlProcessor) UnmarshalFile(file string) (Object, error) {
+	return unmarshalFile(y, file)
+}
@@ -0,0 +1,134 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smithy_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/smithy"
+	"github.com/spf13/viper"
+)
+
+const prodID = "envs/prod/app.json"
+
+// boundValue encrypts value to a new key pair, bound to db.password in
+// the file prodID, and returns it with the private key file
+func boundValue(t *testing.T, value string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	pub, priv := filepath.Join(dir, "public.key"), filepath.Join(dir, "private.key")
+	err := crypt.Generate(pub, priv, crypt.AlgorithmEd25519, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := crypt.LoadEncrypter(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := crypt.EncryptBound(e, []byte(value), "label", crypt.Binding{Path: "db.password", File: prodID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, priv
+}
+
+func TestLoadBinding(t *testing.T) {
+	s, priv := boundValue(t, "hunter2")
+
+	for _, tc := range []struct {
+		name   string
+		object map[string]interface{}
+		fileID string
+		ok     bool
+	}{
+		{"right path and file", map[string]interface{}{"db": map[string]interface{}{"password": s}}, prodID, true},
+		{"moved path", map[string]interface{}{"db": map[string]interface{}{"token": s}}, prodID, false},
+		{"other file", map[string]interface{}{"db": map[string]interface{}{"password": s}}, "envs/staging/app.json", false},
+		{"unknown file", map[string]interface{}{"db": map[string]interface{}{"password": s}}, "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			file := writeJSON(t, "app.json", tc.object)
+			object, err := smithy.LoadObject(file, smithy.WithPrivateKey(priv), smithy.WithLabel("label"), smithy.WithFileID(tc.fileID))
+			if !tc.ok {
+				if !errors.Is(err, crypt.ErrWrongKey) {
+					t.Errorf("want ErrWrongKey, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := object["db"].(map[string]interface{})["password"]; got != "hunter2" {
+				t.Errorf("db.password = %v", got)
+			}
+		})
+	}
+}
+
+func TestDecryptViperBinding(t *testing.T) {
+	s, priv := boundValue(t, "hunter2")
+
+	for _, tc := range []struct {
+		name string
+		key  string
+		d    smithy.Decrypter
+		want error
+	}{
+		{"right path and file", "db.password", smithy.KeyFile(priv, "label").ForFile(prodID), nil},
+		{"moved path", "db.token", smithy.KeyFile(priv, "label").ForFile(prodID), crypt.ErrWrongKey},
+		{"other file", "db.password", smithy.KeyFile(priv, "label").ForFile("envs/staging/app.json"), crypt.ErrWrongKey},
+		{"unknown path", "db.password", smithy.DecrypterFunc(smithy.KeyFile(priv, "label").DecryptString), smithy.ErrBound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set(tc.key, s)
+			err := smithy.DecryptViper(v, tc.d)
+			if tc.want != nil {
+				if !errors.Is(err, tc.want) {
+					t.Errorf("want %v, got %v", tc.want, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := v.GetString(tc.key); got != "hunter2" {
+				t.Errorf("%s = %q", tc.key, got)
+			}
+		})
+	}
+}
+
+func TestSecretBound(t *testing.T) {
+	s, priv := boundValue(t, "hunter2")
+	smithy.SetDecrypter(smithy.KeyFile(priv, "label"))
+	defer smithy.SetDecrypter(nil)
+
+	b, err := json.Marshal(map[string]string{"password": s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Password smithy.Secret `json:"password"`
+	}
+	err = json.Unmarshal(b, &v)
+	if !errors.Is(err, smithy.ErrBound) {
+		t.Errorf("want ErrBound, got %v", err)
+	}
+}
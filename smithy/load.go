@@ -0,0 +1,268 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package smithy loads configuration files containing ENC[...] values
+// into Go structs. Values are decrypted in memory and failures are
+// returned as errors; nothing is logged and the process is never exited.
+// Errors wrap the sentinels of the crypt and data packages, so callers can
+// use errors.Is to tell a missing key from a wrong one.
+//
+//	var cfg struct {
+//		Mongo struct {
+//			Host     string `json:"host"`
+//			Password string `json:"password"`
+//		} `json:"mongo"`
+//	}
+//	err := smithy.Load("config.yaml", &cfg, smithy.WithPrivateKey("/etc/smithy/private.key"))
+//
+// Files are read with the processors registered in the data package, so
+// JSON, YAML, TOML, dotenv and any plugin formats are supported. Struct
+// fields are matched using their json tags regardless of the file format.
+//
+// Services decoding configuration themselves can type sensitive fields as
+// Secret and register a Decrypter with SetDecrypter instead.
+package smithy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
+)
+
+// DefaultLabel is the label used by the smithy command when none is given
+const DefaultLabel = "label"
+
+// Option configures how a file is read and decrypted
+type Option func(*options)
+
+type options struct {
+	label            string
+	decrypter        crypt.Decrypter
+	format           string
+	file             string
+	fileID           string
+	requireBinding   bool
+	allowWhitespace  bool
+	requireMAC       bool
+	requireSignature bool
+	signers          []data.Signer
+}
+
+// WithLabel sets the label the values were encrypted with
+func WithLabel(label string) Option {
+	return func(o *options) {
+		o.label = label
+	}
+}
+
+// WithPrivateKey sets the private key file used to decrypt values
+func WithPrivateKey(file string) Option {
+	return WithKeyProvider(crypt.FileKey(file))
+}
+
+// WithKeyProvider sets where the private key used to decrypt values comes from
+func WithKeyProvider(p crypt.KeyProvider) Option {
+	return WithDecrypter(crypt.NewDecrypter(p))
+}
+
+// WithDecrypter sets the decrypter used for values
+func WithDecrypter(d crypt.Decrypter) Option {
+	return func(o *options) {
+		o.decrypter = d
+	}
+}
+
+// WithFormat sets the data format instead of relying on the file
+// extension or content detection
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithRequiredBinding rejects encrypted values that are not bound to
+// their field path
+func WithRequiredBinding() Option {
+	return func(o *options) {
+		o.requireBinding = true
+	}
+}
+
+// WithAllowWhitespace accepts encrypted values with whitespace around or
+// inside the envelope, such as base64 wrapped over several lines
+func WithAllowWhitespace() Option {
+	return func(o *options) {
+		o.allowWhitespace = true
+	}
+}
+
+// WithFileID sets the identity of the file, needed by values bound to
+// their file. It must match the identity given when encrypting, such as
+// the --file-id flag or the path below --bind-root.
+func WithFileID(id string) Option {
+	return func(o *options) {
+		o.fileID = id
+	}
+}
+
+// WithRequiredMAC rejects documents without a valid document MAC. A MAC
+// present in a document is always verified.
+func WithRequiredMAC() Option {
+	return func(o *options) {
+		o.requireMAC = true
+	}
+}
+
+// WithSigners rejects documents that are not signed by one of signers.
+// The signature is read from the smithy metadata block, or from the
+// sidecar .sig file next to a loaded file. Without any signers every
+// document is rejected.
+func WithSigners(signers ...data.Signer) Option {
+	return func(o *options) {
+		o.requireSignature = true
+		o.signers = signers
+	}
+}
+
+// Load reads file, decrypts all encrypted values and unmarshals the
+// result into v
+func Load(file string, v interface{}, opts ...Option) error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	o := newOptions(opts)
+	o.file = file
+	if o.format == "" {
+		o.format = data.FormatFromExt(filepath.Ext(file))
+	}
+	return data.InFile(decode(b, v, o), file)
+}
+
+// Decode reads a document from r, decrypts all encrypted values and
+// unmarshals the result into v
+func Decode(r io.Reader, v interface{}, opts ...Option) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return decode(b, v, newOptions(opts))
+}
+
+// LoadObject reads file and returns its decrypted contents
+func LoadObject(file string, opts ...Option) (data.Object, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newOptions(opts)
+	o.file = file
+	if o.format == "" {
+		o.format = data.FormatFromExt(filepath.Ext(file))
+	}
+	object, err := decryptObject(b, o)
+	return object, data.InFile(err, file)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{label: DefaultLabel}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func decode(b []byte, v interface{}, o *options) error {
+	object, err := decryptObject(b, o)
+	if err != nil {
+		return err
+	}
+
+	j, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, v)
+}
+
+func decryptObject(b []byte, o *options) (data.Object, error) {
+	if o.decrypter == nil {
+		return nil, fmt.Errorf("smithy: no private key given: %w", crypt.ErrKeyNotFound)
+	}
+
+	format := o.format
+	if format == "" {
+		format = data.Detect(b)
+	}
+	p, err := data.NewProcessor(format)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := p.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	if o.requireSignature {
+		err = verifySignature(object, o)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if object.HasMAC() || o.requireMAC {
+		err = object.VerifyMAC(o.decrypter, o.label)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := data.DecryptOptions{
+		Label:           o.label,
+		File:            o.fileID,
+		RequireBinding:  o.requireBinding,
+		AllowWhitespace: o.allowWhitespace,
+	}
+	err = object.DecryptValuesWith(o.decrypter, opts)
+	if err != nil {
+		return nil, err
+	}
+	object.RemoveMetadata()
+	return object, nil
+}
+
+// verifySignature checks the signature of a decoded document against the
+// signers of o
+func verifySignature(object data.Object, o *options) error {
+	if o.file != "" {
+		_, err := object.VerifyFile(o.file, o.signers)
+		return err
+	}
+
+	sig := object.InlineSignature()
+	if sig == nil {
+		return data.ErrNoSignature
+	}
+	_, err := object.Verify(sig, o.signers)
+	return err
+}
@@ -0,0 +1,109 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smithy_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
+	"github.com/mshindle/smithy/smithy"
+)
+
+// writeJSON saves object as a JSON file in a temporary directory
+func writeJSON(t *testing.T, name string, object interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), name)
+	err = ioutil.WriteFile(file, b, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadAllowWhitespace(t *testing.T) {
+	e, d := newTransit(t)
+	s, err := crypt.EncryptWith(e, []byte("hunter2"), "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped := s[:len(s)/2] + "\n  " + s[len(s)/2:] + "\n"
+	file := writeJSON(t, "app.json", map[string]string{"password": wrapped})
+
+	_, err = smithy.LoadObject(file, smithy.WithDecrypter(d), smithy.WithLabel("label"))
+	if err == nil {
+		t.Fatal("wrapped value was decrypted without WithAllowWhitespace")
+	}
+
+	object, err := smithy.LoadObject(file, smithy.WithDecrypter(d), smithy.WithLabel("label"), smithy.WithAllowWhitespace())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := object["password"]; got != "hunter2" {
+		t.Errorf("password = %v", got)
+	}
+}
+
+func TestLoadWithSigners(t *testing.T) {
+	_, d := newTransit(t)
+	key, err := crypt.GenerateKey(crypt.AlgorithmEd25519)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := data.Signer{Name: "ci", PublicKey: key.Public()}
+
+	object := data.Object{"a": "b"}
+	unsigned := writeJSON(t, "unsigned.json", object)
+	sig, err := object.Sign(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = object.SetSignature(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := writeJSON(t, "signed.json", object)
+
+	for _, tc := range []struct {
+		name    string
+		file    string
+		signers []data.Signer
+		want    error
+	}{
+		{"trusted", signed, []data.Signer{signer}, nil},
+		{"no signers", signed, nil, data.ErrUntrustedSigner},
+		{"empty signers", signed, []data.Signer{}, data.ErrUntrustedSigner},
+		{"unsigned", unsigned, []data.Signer{signer}, data.ErrNoSignature},
+		{"unsigned without signers", unsigned, nil, data.ErrNoSignature},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := smithy.LoadObject(tc.file, smithy.WithDecrypter(d), smithy.WithSigners(tc.signers...))
+			if tc.want == nil && err != nil {
+				t.Fatal(err)
+			}
+			if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Errorf("want %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
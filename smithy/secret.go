@@ -0,0 +1,190 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smithy
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/mshindle/smithy/crypt"
+)
+
+const redacted = "****"
+
+// Decrypter turns an ENC[...] string back into its plaintext
+type Decrypter interface {
+	DecryptString(s string) ([]byte, error)
+}
+
+// DecrypterFunc adapts an ordinary function to the Decrypter interface
+type DecrypterFunc func(s string) ([]byte, error)
+
+// DecryptString calls f(s)
+func (f DecrypterFunc) DecryptString(s string) ([]byte, error) {
+	return f(s)
+}
+
+// BoundDecrypter is a Decrypter that also decrypts values bound to the
+// field path they are stored at, and to their file if it has an identity.
+// DecryptViper passes it the path of each setting.
+type BoundDecrypter interface {
+	Decrypter
+	DecryptBound(s string, path string) ([]byte, error)
+}
+
+// ErrBound is returned when a value bound to its field path is decrypted
+// without knowing the path, such as by a Secret or a plain Decrypter
+var ErrBound = errors.New("smithy: value is bound to its field path, decrypt it with smithy.Load or a BoundDecrypter")
+
+// KeyDecrypter decrypts values with a private key and the label they
+// were encrypted with. It is a BoundDecrypter.
+type KeyDecrypter struct {
+	d     crypt.Decrypter
+	label string
+	file  string
+}
+
+// KeyFile returns a Decrypter using the private key in file and the label
+// the values were encrypted with
+func KeyFile(file string, label string) *KeyDecrypter {
+	return Key(crypt.FileKey(file), label)
+}
+
+// Key returns a Decrypter using the private key supplied by p and the
+// label the values were encrypted with
+func Key(p crypt.KeyProvider, label string) *KeyDecrypter {
+	return &KeyDecrypter{d: crypt.NewDecrypter(p), label: label}
+}
+
+// ForFile returns a copy of k for values bound to the file with identity
+// id, as given by the --file-id flag or the path below --bind-root when
+// they were encrypted
+func (k *KeyDecrypter) ForFile(id string) *KeyDecrypter {
+	c := *k
+	c.file = id
+	return &c
+}
+
+// DecryptString decrypts s, failing with ErrBound if it is bound
+func (k *KeyDecrypter) DecryptString(s string) ([]byte, error) {
+	if crypt.IsBound(s) {
+		return nil, ErrBound
+	}
+	return crypt.DecryptWith(k.d, s, k.label)
+}
+
+// DecryptBound decrypts s stored at the dotted path
+func (k *KeyDecrypter) DecryptBound(s string, path string) ([]byte, error) {
+	return crypt.DecryptBound(k.d, s, k.label, crypt.Binding{Path: path, File: k.file})
+}
+
+var (
+	decrypterMu sync.RWMutex
+	decrypter   Decrypter
+)
+
+// SetDecrypter registers the Decrypter used when unmarshaling Secret values
+func SetDecrypter(d Decrypter) {
+	decrypterMu.Lock()
+	defer decrypterMu.Unlock()
+	decrypter = d
+}
+
+func currentDecrypter() Decrypter {
+	decrypterMu.RLock()
+	defer decrypterMu.RUnlock()
+	return decrypter
+}
+
+// Secret holds a sensitive string. When unmarshaled from an ENC[...] value
+// it is decrypted with the Decrypter registered by SetDecrypter; any other
+// value is kept as is. Secrets print and marshal as **** so the plaintext
+// does not end up in logs; use Plaintext to read the value. Values bound
+// to their field path fail with ErrBound, as a Secret does not know where
+// it is stored; Load decrypts them before unmarshaling instead.
+type Secret struct {
+	plaintext string
+}
+
+// NewSecret wraps a plaintext value
+func NewSecret(plaintext string) Secret {
+	return Secret{plaintext: plaintext}
+}
+
+// Plaintext returns the decrypted value
+func (s Secret) Plaintext() string {
+	return s.plaintext
+}
+
+// String returns a redacted placeholder
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString returns a redacted placeholder for %#v
+func (s Secret) GoString() string {
+	return "smithy.Secret(" + redacted + ")"
+}
+
+// MarshalText returns a redacted placeholder
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// UnmarshalText decrypts text if it is an ENC[...] value
+func (s *Secret) UnmarshalText(text []byte) error {
+	value := string(text)
+	if !crypt.IsEncrypted(value) {
+		s.plaintext = value
+		return nil
+	}
+
+	if crypt.IsBound(value) {
+		return ErrBound
+	}
+
+	d := currentDecrypter()
+	if d == nil {
+		return errors.New("smithy: no decrypter registered for secret")
+	}
+
+	b, err := d.DecryptString(value)
+	if err != nil {
+		return err
+	}
+	s.plaintext = string(b)
+	return nil
+}
+
+// UnmarshalJSON decrypts a JSON string value
+func (s *Secret) UnmarshalJSON(b []byte) error {
+	var value string
+	err := json.Unmarshal(b, &value)
+	if err != nil {
+		return err
+	}
+	return s.UnmarshalText([]byte(value))
+}
+
+// UnmarshalYAML decrypts a YAML string value
+func (s *Secret) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var value string
+	err := unmarshal(&value)
+	if err != nil {
+		return err
+	}
+	return s.UnmarshalText([]byte(value))
+}
@@ -0,0 +1,125 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smithy
+
+import (
+	"fmt"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/data"
+	"github.com/spf13/viper"
+)
+
+// ReadInConfig reads the configuration of v and decrypts it with d
+func ReadInConfig(v *viper.Viper, d Decrypter) error {
+	err := v.ReadInConfig()
+	if err != nil {
+		return err
+	}
+	return DecryptViper(v, d)
+}
+
+// DecryptViper decrypts every ENC[...] string held by v, including those
+// nested in maps and lists, and stores the plaintext back into v so that
+// v.GetString("mongo.password") returns the decrypted value. It should be
+// called again after v re-reads its configuration.
+//
+// Values bound to their field path need a BoundDecrypter, such as the one
+// returned by Key, and fail with ErrBound otherwise. viper lowercases keys,
+// so such values must be bound to lowercase paths.
+func DecryptViper(v *viper.Viper, d Decrypter) error {
+	for key, value := range v.AllSettings() {
+		err := decryptViperValue(v, d, key, value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decryptViperValue walks value found at key. Strings are set back into v
+// individually, while lists are replaced as a whole.
+func decryptViperValue(v *viper.Viper, d Decrypter, key string, value interface{}) error {
+	if m, ok := value.(map[string]interface{}); ok {
+		for k, child := range m {
+			err := decryptViperValue(v, d, key+"."+k, child)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	out, changed, err := decryptTree(d, key, value)
+	if err != nil {
+		return err
+	}
+	if changed {
+		v.Set(key, out)
+	}
+	return nil
+}
+
+// decryptTree returns a copy of value with every encrypted string decrypted
+// and reports whether anything changed
+func decryptTree(d Decrypter, path string, value interface{}) (interface{}, bool, error) {
+	switch t := value.(type) {
+	case string:
+		return decryptViperString(d, path, t)
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		changed := false
+		for k, child := range t {
+			c, ok, err := decryptTree(d, path+"."+k, child)
+			if err != nil {
+				return nil, false, err
+			}
+			out[k], changed = c, changed || ok
+		}
+		return out, changed, nil
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		changed := false
+		for i, child := range t {
+			c, ok, err := decryptTree(d, fmt.Sprintf("%s[%d]", path, i), child)
+			if err != nil {
+				return nil, false, err
+			}
+			out[i], changed = c, changed || ok
+		}
+		return out, changed, nil
+	}
+	return value, false, nil
+}
+
+func decryptViperString(d Decrypter, key string, s string) (interface{}, bool, error) {
+	if !crypt.IsEncrypted(s) {
+		return s, false, nil
+	}
+
+	var b []byte
+	var err error
+	if bd, ok := d.(BoundDecrypter); ok {
+		b, err = bd.DecryptBound(s, key)
+	} else if crypt.IsBound(s) {
+		err = ErrBound
+	} else {
+		b, err = d.DecryptString(s)
+	}
+	if err != nil {
+		return "", false, &data.FieldError{Path: key, Err: err}
+	}
+	return string(b), true, nil
+}
@@ -0,0 +1,84 @@
+// Copyright © 2017 Michael Shindle <mshindle@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package smithy_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mshindle/smithy/crypt"
+	"github.com/mshindle/smithy/crypt/kmstest"
+	"github.com/mshindle/smithy/data"
+	"github.com/mshindle/smithy/smithy"
+	"github.com/spf13/viper"
+)
+
+// newTransit returns an Encrypter and Decrypter backed by a fake Vault
+// transit key, closed at the end of the test
+func newTransit(t *testing.T) (crypt.Encrypter, crypt.Decrypter) {
+	t.Helper()
+	server := kmstest.NewVaultServer("token")
+	t.Cleanup(server.Close)
+	transit := server.Transit("smithy")
+	return crypt.NewKMSEncrypter(transit), crypt.NewKMSDecrypter(transit)
+}
+
+// labelDecrypter decrypts values encrypted with label using d
+func labelDecrypter(d crypt.Decrypter, label string) smithy.Decrypter {
+	return smithy.DecrypterFunc(func(s string) ([]byte, error) {
+		return crypt.DecryptWith(d, s, label)
+	})
+}
+
+func TestDecryptViper(t *testing.T) {
+	e, d := newTransit(t)
+	s, err := crypt.EncryptWith(e, []byte("hunter2"), "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	v.Set("db.password", s)
+	v.Set("hosts", []interface{}{"plain", s})
+	err = smithy.DecryptViper(v, labelDecrypter(d, "label"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := v.GetString("db.password"); got != "hunter2" {
+		t.Errorf("db.password = %q", got)
+	}
+	if got := v.GetStringSlice("hosts"); len(got) != 2 || got[1] != "hunter2" {
+		t.Errorf("hosts = %q", got)
+	}
+}
+
+func TestDecryptViperKeepsErrors(t *testing.T) {
+	e, d := newTransit(t)
+	s, err := crypt.EncryptWith(e, []byte("hunter2"), "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	v.Set("db.password", s)
+	err = smithy.DecryptViper(v, labelDecrypter(d, "other"))
+	if !errors.Is(err, crypt.ErrWrongKey) {
+		t.Errorf("want ErrWrongKey, got %v", err)
+	}
+	var fe *data.FieldError
+	if !errors.As(err, &fe) || fe.Path != "db.password" {
+		t.Errorf("want a field error at db.password, got %v", err)
+	}
+}